@@ -1,18 +1,53 @@
 package db
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"time"
 
 	"github.com/aperturerobotics/objstore/db"
 	"github.com/dgraph-io/badger"
 )
 
+// userMetaPut is the user meta set on values written by BadgerDB.
+// Subscriptions do not indicate deletes, so this distinguishes puts.
+const userMetaPut byte = 1
+
+// badgerPrefix is the prefix of keys used internally by badger.
+var badgerPrefix = []byte("!badger!")
+
+// ErrReservedPrefix is returned when dropping a prefix which would also drop
+// keys used internally by badger.
+var ErrReservedPrefix = errors.New("prefix overlaps keys reserved by badger")
+
+// ErrReadOnly is returned when dropping keys from a read-only database.
+var ErrReadOnly = errors.New("badger database is read-only")
+
+// newEntry builds a new entry marked with userMetaPut.
+func newEntry(key, val []byte) *badger.Entry {
+	return badger.NewEntry(key, val).WithMeta(userMetaPut)
+}
+
 // BadgerDB implements Db with badger.
+// Requires badger v1.6 or later, for entry TTLs and the options builders.
 type BadgerDB struct {
 	*badger.DB
+
+	// gcCancel stops the value log garbage collector, if running.
+	gcCancel context.CancelFunc
+	// gcDone is closed when the value log garbage collector exits.
+	gcDone chan struct{}
+	// tmpDir is a temporary directory removed on Close, if set.
+	tmpDir string
+	// readOnly indicates the database was opened read-only by OpenBadgerDB.
+	readOnly bool
 }
 
 // NewBadgerDB builds a new badger database.
+// The caller retains ownership of db, use OpenBadgerDB to manage its lifecycle.
+// Badger does not report if db was opened read-only, in which case DropPrefix
+// panics, use OpenBadgerDB to open read-only databases.
 func NewBadgerDB(db *badger.DB) db.Db {
 	return &BadgerDB{DB: db}
 }
@@ -23,52 +58,170 @@ func (d *BadgerDB) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
 	var objVal []byte
 	var objFound bool
 	getErr := d.View(func(txn *badger.Txn) error {
-		item, rerr := txn.Get(key)
-		if rerr != nil {
-			if rerr == badger.ErrKeyNotFound {
-				return nil
-			}
-			return rerr
-		}
-
-		objFound = true
-		return item.Value(func(val []byte) error {
-			objVal = make([]byte, len(val))
-			copy(objVal, val)
-			return nil
-		})
+		var err error
+		objVal, objFound, err = txnGet(txn, key)
+		return err
 	})
 	return objVal, objFound, getErr
 }
 
+// txnGet retrieves a copy of a value in a transaction.
+func txnGet(txn *badger.Txn, key []byte) ([]byte, bool, error) {
+	item, err := txn.Get(key)
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
+
 // Set sets an object in the database.
 func (d *BadgerDB) Set(ctx context.Context, key []byte, val []byte) error {
 	return d.DB.Update(func(txn *badger.Txn) error {
-		return txn.Set(key, val)
+		return txn.SetEntry(newEntry(key, val))
+	})
+}
+
+// SetWithTTL sets an object in the database which expires after the ttl.
+// Badger tracks expiry in whole seconds, so the expiry is rounded up to the
+// next second, and the key may be kept for up to a second longer than the ttl.
+func (d *BadgerDB) SetWithTTL(ctx context.Context, key []byte, val []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return d.Set(ctx, key, val)
+	}
+
+	ent := newEntry(key, val)
+	ent.ExpiresAt = expiresAt(time.Now().Add(ttl))
+	return d.DB.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(ent)
 	})
 }
 
+// expiresAt converts an expiry time to a badger expiry, rounding up to the
+// next second. Badger considers a key expired once the expiry is not after
+// the current unix time in seconds.
+func expiresAt(t time.Time) uint64 {
+	secs := t.Unix()
+	if t.Nanosecond() != 0 {
+		secs++
+	}
+	return uint64(secs)
+}
+
+// GetTTL returns the remaining ttl of a key, zero if the key does not expire.
+func (d *BadgerDB) GetTTL(ctx context.Context, key []byte) (time.Duration, bool, error) {
+	var ttl time.Duration
+	var found bool
+	getErr := d.View(func(txn *badger.Txn) error {
+		var err error
+		ttl, found, err = txnGetTTL(txn, key)
+		return err
+	})
+	return ttl, found, getErr
+}
+
+// txnGetTTL returns the remaining ttl of a key in a transaction.
+func txnGetTTL(txn *badger.Txn, key []byte) (time.Duration, bool, error) {
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	expiresAt := item.ExpiresAt()
+	if expiresAt == 0 {
+		return 0, true, nil
+	}
+
+	ttl := time.Until(time.Unix(int64(expiresAt), 0))
+	if ttl <= 0 {
+		return 0, false, nil
+	}
+	return ttl, true, nil
+}
+
 // List lists keys in the database.
 func (d *BadgerDB) List(ctx context.Context, prefix []byte) ([][]byte, error) {
 	var vals [][]byte
+	err := d.DB.View(func(txn *badger.Txn) error {
+		var err error
+		vals, err = txnList(ctx, txn, prefix)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// txnList lists keys with a prefix in a transaction.
+func txnList(ctx context.Context, txn *badger.Txn, prefix []byte) ([][]byte, error) {
+	var vals [][]byte
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		item := it.Item()
+		k := item.Key()
+		kb := make([]byte, len(k))
+		copy(kb, k)
+		vals = append(vals, kb)
+	}
+	return vals, nil
+}
+
+// ListPage lists a page of keys with a prefix in ascending order.
+func (d *BadgerDB) ListPage(ctx context.Context, prefix, startAfter []byte, limit int) ([][]byte, []byte, error) {
+	var keys [][]byte
+	var cursor []byte
 	err := d.DB.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-			k := item.Key()
-			kb := make([]byte, len(k))
-			copy(kb, k)
-			vals = append(vals, kb)
+		defer it.Close()
+
+		start := prefix
+		if bytes.Compare(startAfter, start) > 0 {
+			start = startAfter
+		}
+		it.Seek(start)
+		if startAfter != nil && it.Valid() && bytes.Equal(it.Item().Key(), startAfter) {
+			it.Next()
+		}
+
+		for ; it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if limit > 0 && len(keys) == limit {
+				cursor = keys[len(keys)-1]
+				return nil
+			}
+
+			keys = append(keys, it.Item().KeyCopy(nil))
 		}
 		return nil
 	})
-
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return vals, nil
+
+	return keys, cursor, nil
 }
 
 // Delete deletes a set of keys from the db.
@@ -92,3 +245,209 @@ func (d *BadgerDB) Delete(ctx context.Context, keys ...[]byte) error {
 		return nil
 	})
 }
+
+// CompareAndSwap sets the key to val if the current value equals old.
+// The compare and set are made in a transaction, which is retried if a
+// concurrent write to the key conflicts with it.
+func (d *BadgerDB) CompareAndSwap(ctx context.Context, key []byte, old, val []byte) (bool, error) {
+	for {
+		var swapped bool
+		err := d.DB.Update(func(txn *badger.Txn) error {
+			swapped = false
+			item, err := txn.Get(key)
+			if err != nil && err != badger.ErrKeyNotFound {
+				return err
+			}
+
+			if old == nil {
+				if err == nil {
+					return nil
+				}
+			} else {
+				if err == badger.ErrKeyNotFound {
+					return nil
+				}
+
+				cur, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(cur, old) {
+					return nil
+				}
+			}
+
+			swapped = true
+			return txn.SetEntry(newEntry(key, val))
+		})
+		if err == badger.ErrConflict {
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+			continue
+		}
+
+		return swapped, err
+	}
+}
+
+// SetIfNotExists sets the key to val if the key does not exist.
+func (d *BadgerDB) SetIfNotExists(ctx context.Context, key []byte, val []byte) (bool, error) {
+	return d.CompareAndSwap(ctx, key, nil, val)
+}
+
+// DropPrefix deletes all keys with a prefix with badger's DropPrefix.
+// An empty prefix drops all keys with badger's DropAll. Prefixes of badger's
+// internal keys are rejected with ErrReservedPrefix, as dropping them loses
+// the value log head and dropped keys reappear when the db is reopened.
+// Writes are blocked while the keys are dropped. Badger v1.6 DropAll is not
+// safe to run alongside reads, which may panic, so reads and writes must be
+// paused by the caller while dropping all keys.
+// Returns ErrReadOnly if the database was opened read-only.
+func (d *BadgerDB) DropPrefix(ctx context.Context, prefix []byte) error {
+	if d.readOnly {
+		return ErrReadOnly
+	}
+	if len(prefix) == 0 {
+		return d.DB.DropAll()
+	}
+	if bytes.HasPrefix(badgerPrefix, prefix) {
+		return ErrReservedPrefix
+	}
+
+	return d.DB.DropPrefix(prefix)
+}
+
+// Snapshot returns a read-only view of the database at this point in time,
+// backed by a read-only badger transaction. Iterators built from the snapshot
+// must be closed before it is released.
+func (d *BadgerDB) Snapshot(ctx context.Context) (db.ReadOnlyDb, func(), error) {
+	txn := d.DB.NewTransaction(false)
+	return &badgerSnapshot{txn: txn}, txn.Discard, nil
+}
+
+// badgerSnapshot is a read-only view of the database in a transaction.
+type badgerSnapshot struct {
+	txn *badger.Txn
+}
+
+// Get retrieves an object from the snapshot.
+func (s *badgerSnapshot) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	return txnGet(s.txn, key)
+}
+
+// List lists keys with a prefix in the snapshot.
+func (s *badgerSnapshot) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	return txnList(ctx, s.txn, prefix)
+}
+
+// GetTTL returns the remaining ttl of a key in the snapshot.
+func (s *badgerSnapshot) GetTTL(ctx context.Context, key []byte) (time.Duration, bool, error) {
+	return txnGetTTL(s.txn, key)
+}
+
+// NewIterator builds a new iterator over the snapshot.
+func (s *badgerSnapshot) NewIterator(ctx context.Context, opts db.IteratorOpts) (db.Iterator, error) {
+	return newBadgerIterator(s.txn, opts, false), nil
+}
+
+// Watch watches for changes to keys with a prefix with badger's Subscribe.
+// Writes made without the BadgerDB wrapper are reported as deletes, and
+// expired keys do not emit events. The subscription is started in the
+// background, so writes made immediately after Watch returns may be missed.
+func (d *BadgerDB) Watch(ctx context.Context, prefix []byte) (<-chan db.Event, error) {
+	subCtx, subCancel := context.WithCancel(ctx)
+	ch := db.NewWatchChan()
+	go func() {
+		defer close(ch)
+		defer subCancel()
+
+		_ = d.DB.Subscribe(subCtx, func(kvs *badger.KVList) error {
+			for _, kv := range kvs.GetKv() {
+				if bytes.HasPrefix(kv.GetKey(), badgerPrefix) {
+					continue
+				}
+
+				// badger publishes the entry user meta in Meta
+				ev := db.Event{Type: db.EventDelete, Key: kv.GetKey()}
+				if meta := kv.GetMeta(); len(meta) != 0 && meta[0]&userMetaPut != 0 {
+					ev.Type = db.EventPut
+					ev.Value = kv.GetValue()
+				}
+
+				if !db.SendWatchEvent(ch, ev) {
+					// the receiver fell behind
+					subCancel()
+					return context.Canceled
+				}
+			}
+			return nil
+		}, prefix)
+	}()
+
+	return ch, nil
+}
+
+// NewTxn builds a new transaction backed by a badger transaction.
+func (d *BadgerDB) NewTxn(ctx context.Context, write bool) (db.Txn, error) {
+	return &badgerTxn{txn: d.DB.NewTransaction(write)}, nil
+}
+
+// badgerTxn implements db.Txn with a badger transaction.
+type badgerTxn struct {
+	txn *badger.Txn
+}
+
+// Get retrieves an object from the transaction.
+func (t *badgerTxn) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	return txnGet(t.txn, key)
+}
+
+// Set sets an object in the transaction.
+// Badger references the key and value until commit, so they are copied.
+func (t *badgerTxn) Set(ctx context.Context, key []byte, val []byte) error {
+	return t.txn.SetEntry(newEntry(copyBytes(key), copyBytes(val)))
+}
+
+// Delete deletes a set of keys in the transaction.
+func (t *badgerTxn) Delete(ctx context.Context, keys ...[]byte) error {
+	for _, key := range keys {
+		if err := t.txn.Delete(copyBytes(key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Commit commits the transaction.
+func (t *badgerTxn) Commit(ctx context.Context) error {
+	return t.txn.Commit()
+}
+
+// Discard discards the transaction.
+func (t *badgerTxn) Discard() {
+	t.txn.Discard()
+}
+
+// copyBytes copies a byte slice, returning an empty slice for nil.
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// _ are type assertions
+var (
+	_ db.Batcher           = &BadgerDB{}
+	_ db.ClosableDb        = &BadgerDB{}
+	_ db.CompareAndSwapper = &BadgerDB{}
+	_ db.Pager             = &BadgerDB{}
+	_ db.PrefixDropper     = &BadgerDB{}
+	_ db.Snapshotter       = &BadgerDB{}
+	_ db.TTLGetter         = &BadgerDB{}
+	_ db.TTLSetter         = &BadgerDB{}
+	_ db.Watcher           = &BadgerDB{}
+	_ db.Iterable          = &badgerSnapshot{}
+	_ db.TTLGetter         = &badgerSnapshot{}
+)
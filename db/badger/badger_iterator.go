@@ -0,0 +1,140 @@
+package db
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/dgraph-io/badger"
+)
+
+// badgerIterator implements db.Iterator with a badger iterator.
+type badgerIterator struct {
+	txn     *badger.Txn
+	ownTxn  bool
+	it      *badger.Iterator
+	lower   []byte
+	upper   []byte
+	reverse bool
+	limit   int
+	count   int
+	err     error
+}
+
+// NewIterator builds a new iterator within a read-only transaction.
+func (d *BadgerDB) NewIterator(ctx context.Context, opts db.IteratorOpts) (db.Iterator, error) {
+	return newBadgerIterator(d.DB.NewTransaction(false), opts, true), nil
+}
+
+// newBadgerIterator builds a new iterator in a transaction.
+// If ownTxn is set, the transaction is discarded when the iterator is closed.
+func newBadgerIterator(txn *badger.Txn, opts db.IteratorOpts, ownTxn bool) *badgerIterator {
+	iopts := badger.DefaultIteratorOptions
+	iopts.Reverse = opts.Reverse
+	lower, upper := opts.Bounds()
+	it := &badgerIterator{
+		txn:     txn,
+		ownTxn:  ownTxn,
+		it:      txn.NewIterator(iopts),
+		lower:   lower,
+		upper:   upper,
+		reverse: opts.Reverse,
+		limit:   opts.Limit,
+	}
+	it.Seek(nil)
+	return it
+}
+
+// Seek moves the iterator to the key.
+func (i *badgerIterator) Seek(key []byte) {
+	i.count = 0
+	if !i.reverse {
+		if bytes.Compare(key, i.lower) < 0 {
+			key = i.lower
+		}
+		i.it.Seek(key)
+		return
+	}
+
+	if key == nil || (i.upper != nil && bytes.Compare(key, i.upper) >= 0) {
+		key = i.upper
+	}
+	if key == nil {
+		i.it.Rewind()
+		return
+	}
+
+	i.it.Seek(key)
+	// the upper bound is exclusive
+	if i.it.Valid() && i.upper != nil && bytes.Equal(i.it.Item().Key(), i.upper) {
+		i.it.Next()
+	}
+}
+
+// Next advances the iterator.
+func (i *badgerIterator) Next() {
+	if !i.Valid() {
+		return
+	}
+
+	i.count++
+	i.it.Next()
+}
+
+// Valid indicates the iterator is positioned at an entry.
+func (i *badgerIterator) Valid() bool {
+	if i.err != nil || !i.it.Valid() {
+		return false
+	}
+	if i.limit != 0 && i.count >= i.limit {
+		return false
+	}
+
+	key := i.it.Item().Key()
+	if bytes.Compare(key, i.lower) < 0 {
+		return false
+	}
+
+	return i.upper == nil || bytes.Compare(key, i.upper) < 0
+}
+
+// Key returns the key at the current position.
+func (i *badgerIterator) Key() []byte {
+	if !i.Valid() {
+		return nil
+	}
+
+	return i.it.Item().Key()
+}
+
+// Value returns a copy of the value at the current position.
+func (i *badgerIterator) Value() ([]byte, error) {
+	if !i.Valid() {
+		return nil, nil
+	}
+
+	val, err := i.it.Item().ValueCopy(nil)
+	if err != nil {
+		i.err = err
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// Err returns any error encountered during iteration.
+func (i *badgerIterator) Err() error {
+	return i.err
+}
+
+// Close releases the iterator, and the transaction if it is owned.
+func (i *badgerIterator) Close() error {
+	i.it.Close()
+	if i.ownTxn {
+		i.txn.Discard()
+	}
+	return nil
+}
+
+// _ is a type assertion
+var _ db.Iterable = &BadgerDB{}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultGCInterval is the default interval between value log GC runs.
+const DefaultGCInterval = 5 * time.Minute
+
+// DefaultGCDiscardRatio is the default fraction of a value log file which must
+// be discardable for it to be rewritten.
+const DefaultGCDiscardRatio = 0.5
+
+// Config configures opening a badger database.
+type Config struct {
+	// Path is the directory to store data in.
+	Path string
+	// SyncWrites syncs writes to disk before they are acknowledged.
+	SyncWrites bool
+	// InMemory stores data in a temporary directory which is removed on Close,
+	// and Path is ignored. Badger v1.6 does not support a true in-memory mode.
+	InMemory bool
+	// ReadOnly opens the database read-only. The value log GC is not run.
+	ReadOnly bool
+	// MaxTableSize is the maximum size of LSM tables in bytes.
+	// If zero, the badger default is used.
+	MaxTableSize int64
+	// ValueLogFileSize is the maximum size of value log files in bytes.
+	// If zero, the badger default is used.
+	ValueLogFileSize int64
+	// GCInterval is the interval between value log GC runs.
+	// If zero, DefaultGCInterval is used. If negative, the GC is not run.
+	GCInterval time.Duration
+	// GCDiscardRatio is the fraction of a value log file which must be
+	// discardable for it to be rewritten. If zero, DefaultGCDiscardRatio is used.
+	GCDiscardRatio float64
+	// Logger logs value log GC failures.
+	// If nil, the logrus standard logger is used.
+	Logger *logrus.Entry
+}
+
+// OpenBadgerDB opens a badger database with a config.
+// The value log GC is run in the background until the database is closed.
+func OpenBadgerDB(conf Config) (*BadgerDB, error) {
+	var tmpDir string
+	path := conf.Path
+	if conf.InMemory {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "objstore-badger-")
+		if err != nil {
+			return nil, err
+		}
+		path = tmpDir
+	}
+
+	opts := badger.DefaultOptions(path).
+		WithSyncWrites(conf.SyncWrites).
+		WithReadOnly(conf.ReadOnly)
+	if conf.MaxTableSize != 0 {
+		opts = opts.WithMaxTableSize(conf.MaxTableSize)
+	}
+	if conf.ValueLogFileSize != 0 {
+		opts = opts.WithValueLogFileSize(conf.ValueLogFileSize)
+	}
+
+	bdb, err := badger.Open(opts)
+	if err != nil {
+		if tmpDir != "" {
+			_ = os.RemoveAll(tmpDir)
+		}
+		return nil, err
+	}
+
+	d := &BadgerDB{DB: bdb, tmpDir: tmpDir, readOnly: conf.ReadOnly}
+	interval := conf.GCInterval
+	if interval == 0 {
+		interval = DefaultGCInterval
+	}
+	if interval > 0 && !conf.ReadOnly {
+		discardRatio := conf.GCDiscardRatio
+		if discardRatio == 0 {
+			discardRatio = DefaultGCDiscardRatio
+		}
+
+		var ctx context.Context
+		ctx, d.gcCancel = context.WithCancel(context.Background())
+		d.gcDone = make(chan struct{})
+		le := conf.Logger
+		if le == nil {
+			le = logrus.NewEntry(logrus.StandardLogger())
+		}
+		go func() {
+			defer close(d.gcDone)
+			d.RunValueLogGCLoop(ctx, le, interval, discardRatio)
+		}()
+	}
+
+	return d, nil
+}
+
+// RunValueLogGCLoop runs the value log GC at the interval until the context is
+// canceled. Each run rewrites value log files until none can be rewritten.
+// Failures other than badger.ErrNoRewrite are logged to le.
+func (d *BadgerDB) RunValueLogGCLoop(ctx context.Context, le *logrus.Entry, interval time.Duration, discardRatio float64) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		for ctx.Err() == nil {
+			if err := d.DB.RunValueLogGC(discardRatio); err != nil {
+				if err != badger.ErrNoRewrite {
+					le.WithError(err).Warn("badger value log gc failed")
+				}
+				break
+			}
+		}
+	}
+}
+
+// Close stops the value log GC and closes the database.
+// If the database was opened in memory, the temporary directory is removed.
+func (d *BadgerDB) Close() error {
+	if d.gcCancel != nil {
+		d.gcCancel()
+		<-d.gcDone
+	}
+
+	err := d.DB.Close()
+	if d.tmpDir != "" {
+		if rerr := os.RemoveAll(d.tmpDir); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/dbtest"
+	"github.com/dgraph-io/badger"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance runs the db conformance suite.
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "objstore-badger-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	var dbs []*badger.DB
+	defer func() {
+		for _, bdb := range dbs {
+			bdb.Close()
+		}
+	}()
+
+	dbtest.RunConformance(t, func() db.Db {
+		bdir, err := ioutil.TempDir(dir, "badger-")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		bdb, err := badger.Open(badger.DefaultOptions(bdir).WithLogger(nil))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		dbs = append(dbs, bdb)
+		return NewBadgerDB(bdb)
+	})
+}
+
+// TestReopen runs the db reopen suite.
+func TestReopen(t *testing.T) {
+	dbtest.RunReopen(t, func(dir string) db.Db {
+		d, err := OpenBadgerDB(Config{Path: dir, SyncWrites: true})
+		require.NoError(t, err)
+		return d
+	})
+}
+
+// TestTTLRoundUp tests short ttls are rounded up to the next second.
+func TestTTLRoundUp(t *testing.T) {
+	require.Equal(t, uint64(10), expiresAt(time.Unix(10, 0)))
+	require.Equal(t, uint64(11), expiresAt(time.Unix(10, 1)))
+
+	ctx := context.Background()
+	d, err := OpenBadgerDB(Config{InMemory: true})
+	require.NoError(t, err)
+	defer d.Close()
+
+	require.NoError(t, d.SetWithTTL(ctx, []byte("/a"), []byte("a"), 10*time.Millisecond))
+	ttl, found, err := d.GetTTL(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, ttl > 0)
+}
+
+// TestDropReservedPrefix tests dropping a prefix of badger's internal keys.
+func TestDropReservedPrefix(t *testing.T) {
+	ctx := context.Background()
+	d, err := OpenBadgerDB(Config{InMemory: true})
+	require.NoError(t, err)
+	defer d.Close()
+
+	require.NoError(t, d.Set(ctx, []byte("!a"), []byte("a")))
+	require.Equal(t, ErrReservedPrefix, d.DropPrefix(ctx, []byte("!")))
+	require.Equal(t, ErrReservedPrefix, d.DropPrefix(ctx, []byte("!badger!")))
+	_, found, err := d.Get(ctx, []byte("!a"))
+	require.NoError(t, err)
+	require.True(t, found)
+}
+
+// TestOpenBadgerDB tests opening and closing a database with a config.
+func TestOpenBadgerDB(t *testing.T) {
+	ctx := context.Background()
+	d, err := OpenBadgerDB(Config{
+		InMemory:       true,
+		GCInterval:     time.Millisecond,
+		GCDiscardRatio: 0.1,
+	})
+	require.NoError(t, err)
+	require.DirExists(t, d.tmpDir)
+
+	require.NoError(t, d.Set(ctx, []byte("/a"), []byte("a")))
+	val, found, err := d.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("a"), val)
+
+	// let the value log gc run
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, db.Close(d))
+	_, err = os.Stat(d.tmpDir)
+	require.True(t, os.IsNotExist(err))
+}
+
+// TestOpenBadgerDBReadOnly tests reopening a database read-only.
+func TestOpenBadgerDBReadOnly(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "objstore-badger-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d, err := OpenBadgerDB(Config{Path: dir, SyncWrites: true})
+	require.NoError(t, err)
+	require.NoError(t, d.Set(ctx, []byte("/a"), []byte("a")))
+	require.NoError(t, d.Close())
+
+	d, err = OpenBadgerDB(Config{Path: dir, ReadOnly: true})
+	require.NoError(t, err)
+	defer d.Close()
+
+	val, found, err := d.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("a"), val)
+	require.Error(t, d.Set(ctx, []byte("/b"), []byte("b")))
+	require.Equal(t, ErrReadOnly, d.DropPrefix(ctx, []byte("/")))
+	require.Equal(t, ErrReadOnly, d.DropPrefix(ctx, nil))
+}
@@ -0,0 +1,131 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/aperturerobotics/objstore/db"
+	"go.etcd.io/bbolt"
+)
+
+// BoltDB implements Db with bbolt.
+// All keys are stored in a single bucket. If the bucket does not exist, as in
+// a read-only database where it was never created, the database is empty.
+type BoltDB struct {
+	*bbolt.DB
+	bucket []byte
+}
+
+// NewBoltDB builds a new bolt database, creating the bucket if necessary.
+func NewBoltDB(bdb *bbolt.DB, bucket []byte) (db.Db, error) {
+	if !bdb.IsReadOnly() {
+		err := bdb.Update(func(tx *bbolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(bucket)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &BoltDB{DB: bdb, bucket: bucket}, nil
+}
+
+// Get retrieves an object from the database.
+// Not found should return nil, nil
+func (d *BoltDB) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	var objVal []byte
+	var objFound bool
+	err := d.DB.View(func(tx *bbolt.Tx) error {
+		objVal, objFound = getCopy(tx.Bucket(d.bucket), key)
+		return nil
+	})
+	return objVal, objFound, err
+}
+
+// Set sets an object in the database.
+func (d *BoltDB) Set(ctx context.Context, key []byte, val []byte) error {
+	return d.DB.Update(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(d.bucket)
+		if bkt == nil {
+			return bbolt.ErrBucketNotFound
+		}
+
+		return bkt.Put(key, val)
+	})
+}
+
+// List lists keys in the database.
+func (d *BoltDB) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	var vals [][]byte
+	err := d.DB.View(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(d.bucket)
+		if bkt == nil {
+			return nil
+		}
+
+		c := bkt.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			kb := make([]byte, len(k))
+			copy(kb, k)
+			vals = append(vals, kb)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// Delete deletes a set of keys from the db.
+func (d *BoltDB) Delete(ctx context.Context, keys ...[]byte) error {
+	return d.DB.Update(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(d.bucket)
+		if bkt == nil {
+			return nil
+		}
+
+		for _, key := range keys {
+			if err := bkt.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// getCopy gets a copy of a value from a bucket, which may be nil.
+// Uses a cursor to distinguish empty values from missing keys.
+func getCopy(bkt *bbolt.Bucket, key []byte) ([]byte, bool) {
+	if bkt == nil {
+		return nil, false
+	}
+
+	k, val := bkt.Cursor().Seek(key)
+	if k == nil || !bytes.Equal(k, key) {
+		return nil, false
+	}
+
+	vc := make([]byte, len(val))
+	copy(vc, val)
+	return vc, true
+}
+
+// copyBytes copies a byte slice, returning an empty slice for nil.
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// _ are type assertions
+var (
+	_ db.ClosableDb = &BoltDB{}
+)
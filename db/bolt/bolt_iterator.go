@@ -0,0 +1,148 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/aperturerobotics/objstore/db"
+	"go.etcd.io/bbolt"
+)
+
+// boltIterator implements db.Iterator with a bolt cursor.
+type boltIterator struct {
+	tx *bbolt.Tx
+	// c is the bucket cursor, nil if the bucket does not exist.
+	c       *bbolt.Cursor
+	lower   []byte
+	upper   []byte
+	reverse bool
+	limit   int
+	count   int
+
+	key []byte
+	val []byte
+}
+
+// NewIterator builds a new iterator within a read-only transaction.
+func (d *BoltDB) NewIterator(ctx context.Context, opts db.IteratorOpts) (db.Iterator, error) {
+	tx, err := d.DB.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+
+	lower, upper := opts.Bounds()
+	it := &boltIterator{
+		tx:      tx,
+		lower:   lower,
+		upper:   upper,
+		reverse: opts.Reverse,
+		limit:   opts.Limit,
+	}
+	if bkt := tx.Bucket(d.bucket); bkt != nil {
+		it.c = bkt.Cursor()
+	}
+	it.Seek(nil)
+	return it, nil
+}
+
+// Seek moves the iterator to the key.
+func (i *boltIterator) Seek(key []byte) {
+	i.count = 0
+	if i.c == nil {
+		return
+	}
+
+	if !i.reverse {
+		if bytes.Compare(key, i.lower) < 0 {
+			key = i.lower
+		}
+		i.key, i.val = i.c.Seek(key)
+		return
+	}
+
+	if key == nil || (i.upper != nil && bytes.Compare(key, i.upper) >= 0) {
+		if i.upper == nil {
+			i.key, i.val = i.c.Last()
+			return
+		}
+
+		// seek to the last key before the exclusive upper bound
+		i.key, i.val = i.c.Seek(i.upper)
+		if i.key == nil {
+			i.key, i.val = i.c.Last()
+		} else {
+			i.key, i.val = i.c.Prev()
+		}
+		return
+	}
+
+	// seek to the last key <= key
+	i.key, i.val = i.c.Seek(key)
+	if i.key == nil {
+		i.key, i.val = i.c.Last()
+	} else if !bytes.Equal(i.key, key) {
+		i.key, i.val = i.c.Prev()
+	}
+}
+
+// Next advances the iterator.
+func (i *boltIterator) Next() {
+	if !i.Valid() {
+		return
+	}
+
+	i.count++
+	if i.reverse {
+		i.key, i.val = i.c.Prev()
+	} else {
+		i.key, i.val = i.c.Next()
+	}
+}
+
+// Valid indicates the iterator is positioned at an entry.
+func (i *boltIterator) Valid() bool {
+	if i.key == nil {
+		return false
+	}
+	if i.limit != 0 && i.count >= i.limit {
+		return false
+	}
+	if bytes.Compare(i.key, i.lower) < 0 {
+		return false
+	}
+
+	return i.upper == nil || bytes.Compare(i.key, i.upper) < 0
+}
+
+// Key returns the key at the current position.
+func (i *boltIterator) Key() []byte {
+	if !i.Valid() {
+		return nil
+	}
+
+	return i.key
+}
+
+// Value returns a copy of the value at the current position.
+func (i *boltIterator) Value() ([]byte, error) {
+	if !i.Valid() {
+		return nil, nil
+	}
+
+	val := make([]byte, len(i.val))
+	copy(val, i.val)
+	return val, nil
+}
+
+// Err returns any error encountered during iteration.
+func (i *boltIterator) Err() error {
+	return nil
+}
+
+// Close releases the iterator and transaction.
+func (i *boltIterator) Close() error {
+	return i.tx.Rollback()
+}
+
+// _ is a type assertion
+var _ db.Iterable = &BoltDB{}
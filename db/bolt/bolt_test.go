@@ -0,0 +1,93 @@
+package bolt
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/dbtest"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+// TestConformance runs the db conformance suite.
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "objstore-bolt-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	var dbs []*bbolt.DB
+	defer func() {
+		for _, bdb := range dbs {
+			bdb.Close()
+		}
+	}()
+
+	dbtest.RunConformance(t, func() db.Db {
+		f, err := ioutil.TempFile(dir, "bolt-")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		f.Close()
+
+		bdb, err := bbolt.Open(filepath.Join(dir, filepath.Base(f.Name())), 0644, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		dbs = append(dbs, bdb)
+
+		d, err := NewBoltDB(bdb, []byte("objstore"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return d
+	})
+}
+
+// TestMissingBucket tests reading a read-only database without the bucket.
+func TestMissingBucket(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "objstore-bolt-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "bolt")
+	bdb, err := bbolt.Open(path, 0644, nil)
+	require.NoError(t, err)
+	require.NoError(t, bdb.Close())
+
+	bdb, err = bbolt.Open(path, 0644, &bbolt.Options{ReadOnly: true})
+	require.NoError(t, err)
+	defer bdb.Close()
+
+	d, err := NewBoltDB(bdb, []byte("objstore"))
+	require.NoError(t, err)
+
+	_, found, err := d.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	keys, err := d.List(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	it, err := db.NewIterator(ctx, d, db.IteratorOpts{Reverse: true})
+	require.NoError(t, err)
+	require.False(t, it.Valid())
+	it.Seek([]byte("/a"))
+	it.Next()
+	require.False(t, it.Valid())
+	require.NoError(t, it.Close())
+
+	txn, err := db.NewTxn(ctx, d, false)
+	require.NoError(t, err)
+	_, found, err = txn.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.False(t, found)
+	txn.Discard()
+}
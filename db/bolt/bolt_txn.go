@@ -0,0 +1,74 @@
+package bolt
+
+import (
+	"context"
+
+	"github.com/aperturerobotics/objstore/db"
+	"go.etcd.io/bbolt"
+)
+
+// NewTxn builds a new transaction backed by a bolt transaction.
+// Bolt allows a single write transaction at a time.
+func (d *BoltDB) NewTxn(ctx context.Context, write bool) (db.Txn, error) {
+	tx, err := d.DB.Begin(write)
+	if err != nil {
+		return nil, err
+	}
+
+	return &boltTxn{tx: tx, bkt: tx.Bucket(d.bucket)}, nil
+}
+
+// boltTxn implements db.Txn with a bolt transaction.
+type boltTxn struct {
+	tx *bbolt.Tx
+	// bkt is the bucket, nil if it does not exist.
+	bkt *bbolt.Bucket
+}
+
+// Get retrieves an object from the transaction.
+func (t *boltTxn) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	val, found := getCopy(t.bkt, key)
+	return val, found, nil
+}
+
+// Set sets an object in the transaction.
+// Bolt references the key and value until the transaction ends, so they are copied.
+func (t *boltTxn) Set(ctx context.Context, key []byte, val []byte) error {
+	if t.bkt == nil {
+		return bbolt.ErrBucketNotFound
+	}
+
+	return t.bkt.Put(copyBytes(key), copyBytes(val))
+}
+
+// Delete deletes a set of keys in the transaction.
+func (t *boltTxn) Delete(ctx context.Context, keys ...[]byte) error {
+	if t.bkt == nil {
+		return nil
+	}
+
+	for _, key := range keys {
+		if err := t.bkt.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Commit commits the transaction.
+func (t *boltTxn) Commit(ctx context.Context) error {
+	if !t.tx.Writable() {
+		return t.tx.Rollback()
+	}
+
+	return t.tx.Commit()
+}
+
+// Discard discards the transaction.
+func (t *boltTxn) Discard() {
+	_ = t.tx.Rollback()
+}
+
+// _ is a type assertion
+var _ db.Batcher = &BoltDB{}
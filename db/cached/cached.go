@@ -0,0 +1,374 @@
+package cached
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+)
+
+// DefaultMaxEntries is the default maximum number of cached entries.
+const DefaultMaxEntries = 1024
+
+// expiryGrace is how long expiry times are kept after keys expire.
+// The underlying db may remove expired keys late, for example badger tracks
+// expiry in whole seconds, and values read in the meantime must not be cached.
+const expiryGrace = time.Minute
+
+// Config configures a cached database.
+type Config struct {
+	// MaxEntries is the maximum number of entries in the cache.
+	// If zero, DefaultMaxEntries is used.
+	MaxEntries int
+	// MaxBytes is the maximum total size of cached keys and values.
+	// If zero, the cache is bounded by entries only.
+	MaxBytes int
+	// MaxAge is the maximum age of a cached entry.
+	// Values set with a ttl through the CachedDb are not served after they
+	// expire, but values set with a ttl directly on the underlying db may be
+	// served until they are evicted, MaxAge bounds how long.
+	// If zero, entries do not age.
+	MaxAge time.Duration
+}
+
+// Stats are cache statistics.
+type Stats struct {
+	// Hits is the number of Gets served from the cache.
+	Hits uint64
+	// Misses is the number of Gets read from the underlying db.
+	Misses uint64
+	// Evictions is the number of entries evicted to stay within bounds.
+	Evictions uint64
+	// Entries is the current number of entries.
+	Entries int
+	// Bytes is the current size of cached keys and values.
+	Bytes int
+}
+
+// cacheEntry is an entry in the cache.
+type cacheEntry struct {
+	key string
+	// val is the value, nil if the key was not found.
+	val   []byte
+	found bool
+	added time.Time
+	// expires is the expiry time of the value, zero if it does not expire.
+	expires time.Time
+}
+
+// size returns the accounted size of the entry.
+func (e *cacheEntry) size() int {
+	return len(e.key) + len(e.val)
+}
+
+// CachedDb caches Get results from a db in a LRU cache.
+// Negative lookups are cached as well. Entries are invalidated on writes made
+// through the CachedDb, writes made directly to the underlying db are not seen.
+type CachedDb struct {
+	db   db.Db
+	conf Config
+
+	// mtx guards the fields below
+	mtx   sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	bytes int
+	// gen is incremented on every write, Gets only fill the cache if no write
+	// happened while reading from the underlying db.
+	gen uint64
+	// expires contains the expiry times of keys set with a ttl through the
+	// CachedDb, entries are removed when the key is overwritten or read after
+	// it was removed from the underlying db, and are swept expiryGrace after
+	// they expire.
+	expires map[string]time.Time
+	// sweepAt is the size of expires at which expired entries are swept.
+	sweepAt int
+	stats   Stats
+}
+
+// NewCachedDb builds a new cached database wrapping d.
+func NewCachedDb(d db.Db, conf Config) *CachedDb {
+	if conf.MaxEntries <= 0 {
+		conf.MaxEntries = DefaultMaxEntries
+	}
+
+	return &CachedDb{
+		db:      d,
+		conf:    conf,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+		expires: make(map[string]time.Time),
+		sweepAt: conf.MaxEntries,
+	}
+}
+
+// Stats returns the current cache statistics.
+func (c *CachedDb) Stats() Stats {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.ll.Len()
+	stats.Bytes = c.bytes
+	return stats
+}
+
+// Purge clears the cache.
+func (c *CachedDb) Purge() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.gen++
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.bytes = 0
+}
+
+// lookup looks up a key in the cache, removing the entry if it is too old or
+// has expired. Expects mtx to be locked.
+func (c *CachedDb) lookup(key string, now time.Time) (*cacheEntry, bool) {
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	ent := elem.Value.(*cacheEntry)
+	expired := !ent.expires.IsZero() && !now.Before(ent.expires)
+	if expired || (c.conf.MaxAge != 0 && now.Sub(ent.added) >= c.conf.MaxAge) {
+		c.removeElement(elem)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(elem)
+	return ent, true
+}
+
+// add adds an entry to the cache, evicting entries as necessary.
+// Expects mtx to be locked.
+func (c *CachedDb) add(ent *cacheEntry) {
+	if c.conf.MaxBytes != 0 && ent.size() > c.conf.MaxBytes {
+		return
+	}
+
+	if elem, ok := c.items[ent.key]; ok {
+		c.removeElement(elem)
+	}
+
+	c.items[ent.key] = c.ll.PushFront(ent)
+	c.bytes += ent.size()
+	for c.ll.Len() > c.conf.MaxEntries || (c.conf.MaxBytes != 0 && c.bytes > c.conf.MaxBytes) {
+		c.removeElement(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+// removeElement removes an element from the cache.
+// Expects mtx to be locked.
+func (c *CachedDb) removeElement(elem *list.Element) {
+	ent := elem.Value.(*cacheEntry)
+	c.ll.Remove(elem)
+	delete(c.items, ent.key)
+	c.bytes -= ent.size()
+}
+
+// invalidate removes keys from the cache.
+func (c *CachedDb) invalidate(keys ...[]byte) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.gen++
+	for _, key := range keys {
+		skey := string(key)
+		if elem, ok := c.items[skey]; ok {
+			c.removeElement(elem)
+		}
+		delete(c.expires, skey)
+	}
+}
+
+// invalidateWithTTL removes a key from the cache and records its expiry time.
+func (c *CachedDb) invalidateWithTTL(key []byte, expires time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.gen++
+	skey := string(key)
+	if elem, ok := c.items[skey]; ok {
+		c.removeElement(elem)
+	}
+	c.expires[skey] = expires
+
+	// sweep expired entries each time the map doubles in size
+	if len(c.expires) >= c.sweepAt {
+		cutoff := time.Now().Add(-expiryGrace)
+		for k, exp := range c.expires {
+			if !cutoff.Before(exp) {
+				delete(c.expires, k)
+			}
+		}
+		c.sweepAt = 2 * len(c.expires)
+		if c.sweepAt < c.conf.MaxEntries {
+			c.sweepAt = c.conf.MaxEntries
+		}
+	}
+}
+
+// Get retrieves an object from the database.
+func (c *CachedDb) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	skey := string(key)
+
+	c.mtx.Lock()
+	now := time.Now()
+	if ent, ok := c.lookup(skey, now); ok {
+		c.stats.Hits++
+		c.mtx.Unlock()
+		if !ent.found {
+			return nil, false, nil
+		}
+		return copyBytes(ent.val), true, nil
+	}
+	c.stats.Misses++
+	gen := c.gen
+	c.mtx.Unlock()
+
+	val, found, err := c.db.Get(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	c.mtx.Lock()
+	if c.gen == gen {
+		ent := &cacheEntry{key: skey, found: found, added: now}
+		expires, hasTTL := c.expires[skey]
+		switch {
+		case !found:
+			delete(c.expires, skey)
+		case !hasTTL:
+		case now.Before(expires):
+			ent.expires = expires
+		default:
+			// expired, but not yet removed from the underlying db
+			ent = nil
+		}
+
+		if ent != nil {
+			if found {
+				ent.val = copyBytes(val)
+			}
+			c.add(ent)
+		}
+	}
+	c.mtx.Unlock()
+
+	return val, found, nil
+}
+
+// Set sets an object in the database.
+func (c *CachedDb) Set(ctx context.Context, key []byte, val []byte) error {
+	defer c.invalidate(key)
+	return c.db.Set(ctx, key, val)
+}
+
+// SetWithTTL sets an object in the database with a ttl.
+// The expiry time is kept with the cached value, so it is not served after it
+// expires.
+func (c *CachedDb) SetWithTTL(ctx context.Context, key []byte, val []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return c.Set(ctx, key, val)
+	}
+
+	defer c.invalidateWithTTL(key, time.Now().Add(ttl))
+	return db.SetWithTTL(ctx, c.db, key, val, ttl)
+}
+
+// GetTTL returns the remaining ttl of a key from the underlying db.
+func (c *CachedDb) GetTTL(ctx context.Context, key []byte) (time.Duration, bool, error) {
+	return db.GetTTL(ctx, c.db, key)
+}
+
+// List returns a list of keys with the specified prefix.
+func (c *CachedDb) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	return c.db.List(ctx, prefix)
+}
+
+// Delete clears a set of keys from the db.
+func (c *CachedDb) Delete(ctx context.Context, keys ...[]byte) error {
+	defer c.invalidate(keys...)
+	return c.db.Delete(ctx, keys...)
+}
+
+// NewIterator builds a new iterator over the underlying db.
+func (c *CachedDb) NewIterator(ctx context.Context, opts db.IteratorOpts) (db.Iterator, error) {
+	return db.NewIterator(ctx, c.db, opts)
+}
+
+// NewTxn builds a new transaction against the underlying db.
+// Reads in the transaction bypass the cache, written keys are invalidated
+// when the transaction is committed.
+func (c *CachedDb) NewTxn(ctx context.Context, write bool) (db.Txn, error) {
+	txn, err := db.NewTxn(ctx, c.db, write)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachedTxn{Txn: txn, c: c}, nil
+}
+
+// cachedTxn tracks keys written in a txn.
+type cachedTxn struct {
+	db.Txn
+	c    *CachedDb
+	keys [][]byte
+}
+
+// Set sets an object in the transaction.
+func (t *cachedTxn) Set(ctx context.Context, key []byte, val []byte) error {
+	if err := t.Txn.Set(ctx, key, val); err != nil {
+		return err
+	}
+
+	t.keys = append(t.keys, copyBytes(key))
+	return nil
+}
+
+// Delete clears a set of keys in the transaction.
+func (t *cachedTxn) Delete(ctx context.Context, keys ...[]byte) error {
+	if err := t.Txn.Delete(ctx, keys...); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		t.keys = append(t.keys, copyBytes(key))
+	}
+	return nil
+}
+
+// Commit commits the transaction, invalidating the written keys.
+func (t *cachedTxn) Commit(ctx context.Context) error {
+	defer t.c.invalidate(t.keys...)
+	return t.Txn.Commit(ctx)
+}
+
+// copyBytes copies a byte slice.
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// Close closes the underlying db.
+func (c *CachedDb) Close() error {
+	return db.Close(c.db)
+}
+
+// _ are type assertions
+var (
+	_ db.Db        = &CachedDb{}
+	_ db.Batcher   = &CachedDb{}
+	_ db.Closer    = &CachedDb{}
+	_ db.Iterable  = &CachedDb{}
+	_ db.TTLGetter = &CachedDb{}
+	_ db.TTLSetter = &CachedDb{}
+)
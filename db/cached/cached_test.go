@@ -0,0 +1,134 @@
+package cached
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/dbtest"
+	"github.com/aperturerobotics/objstore/db/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance runs the db conformance suite.
+func TestConformance(t *testing.T) {
+	dbtest.RunConformance(t, func() db.Db {
+		return NewCachedDb(inmem.NewInmemDb(), Config{MaxEntries: 4, MaxBytes: 64})
+	})
+}
+
+// TestCached tests hits, misses, invalidation and eviction.
+func TestCached(t *testing.T) {
+	ctx := context.Background()
+	under := inmem.NewInmemDb()
+	c := NewCachedDb(under, Config{MaxEntries: 2, MaxBytes: 32})
+
+	require.NoError(t, c.Set(ctx, []byte("/a"), []byte("a")))
+	for i := 0; i < 3; i++ {
+		val, found, err := c.Get(ctx, []byte("/a"))
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, []byte("a"), val)
+	}
+	stats := c.Stats()
+	require.Equal(t, uint64(2), stats.Hits)
+	require.Equal(t, uint64(1), stats.Misses)
+	require.Equal(t, 1, stats.Entries)
+	require.Equal(t, 3, stats.Bytes)
+
+	// negative lookups are cached
+	_, found, err := c.Get(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.False(t, found)
+	require.NoError(t, under.Set(ctx, []byte("/b"), []byte("b")))
+	_, found, err = c.Get(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// writes invalidate
+	require.NoError(t, c.Set(ctx, []byte("/b"), []byte("b2")))
+	val, found, err := c.Get(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("b2"), val)
+	require.NoError(t, c.Delete(ctx, []byte("/a")))
+	_, found, err = c.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// bounded by entries
+	_, _, err = c.Get(ctx, []byte("/c"))
+	require.NoError(t, err)
+	stats = c.Stats()
+	require.Equal(t, 2, stats.Entries)
+	require.Equal(t, uint64(1), stats.Evictions)
+
+	// bounded by bytes
+	require.NoError(t, c.Set(ctx, []byte("/big"), make([]byte, 28)))
+	_, _, err = c.Get(ctx, []byte("/big"))
+	require.NoError(t, err)
+	stats = c.Stats()
+	require.Equal(t, 1, stats.Entries)
+	require.Equal(t, 32, stats.Bytes)
+
+	// values too large for the cache are not cached
+	require.NoError(t, c.Set(ctx, []byte("/huge"), make([]byte, 64)))
+	_, _, err = c.Get(ctx, []byte("/huge"))
+	require.NoError(t, err)
+	require.Equal(t, 1, c.Stats().Entries)
+
+	// committed txn writes invalidate
+	txn, err := c.NewTxn(ctx, true)
+	require.NoError(t, err)
+	require.NoError(t, txn.Set(ctx, []byte("/big"), []byte("small")))
+	require.NoError(t, txn.Commit(ctx))
+	txn.Discard()
+	val, _, err = c.Get(ctx, []byte("/big"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("small"), val)
+}
+
+// TestTTL tests values set with a ttl are not served after they expire.
+func TestTTL(t *testing.T) {
+	ctx := context.Background()
+	c := NewCachedDb(inmem.NewInmemDb(), Config{})
+
+	require.NoError(t, c.SetWithTTL(ctx, []byte("/a"), []byte("a"), 50*time.Millisecond))
+	require.NoError(t, c.SetWithTTL(ctx, []byte("/b"), []byte("b"), 50*time.Millisecond))
+	require.NoError(t, c.Set(ctx, []byte("/b"), []byte("b")))
+	for _, key := range []string{"/a", "/b"} {
+		_, found, err := c.Get(ctx, []byte(key))
+		require.NoError(t, err)
+		require.True(t, found)
+	}
+	require.Equal(t, 2, c.Stats().Entries)
+
+	time.Sleep(50 * time.Millisecond)
+	_, found, err := c.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// the value set without a ttl is still cached
+	val, found, err := c.Get(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("b"), val)
+	require.Equal(t, uint64(1), c.Stats().Hits)
+	require.Empty(t, c.expires)
+}
+
+// TestSweepExpires tests expiry times are swept after the keys expire.
+func TestSweepExpires(t *testing.T) {
+	ctx := context.Background()
+	c := NewCachedDb(inmem.NewInmemDb(), Config{MaxEntries: 4})
+
+	require.NoError(t, c.SetWithTTL(ctx, []byte("/live"), []byte("a"), time.Hour))
+	expired := time.Now().Add(-2 * expiryGrace)
+	for i := 0; i < 100; i++ {
+		c.invalidateWithTTL([]byte(fmt.Sprintf("/expired/%d", i)), expired)
+	}
+	require.True(t, len(c.expires) <= 4)
+	require.Contains(t, c.expires, "/live")
+}
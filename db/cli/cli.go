@@ -2,17 +2,26 @@ package cli
 
 import (
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/aperturerobotics/objstore/db"
-	"github.com/dgraph-io/badger"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
+	"go.etcd.io/bbolt"
 
 	dbadger "github.com/aperturerobotics/objstore/db/badger"
+	dbolt "github.com/aperturerobotics/objstore/db/bolt"
+	"github.com/aperturerobotics/objstore/db/fsdb"
 	"github.com/aperturerobotics/objstore/db/inmem"
 )
 
+// defaultDbPath is the path to store data in if the db path is not set.
+// It is not used with the inmem db type, which does not persist data without
+// an explicit path.
+const defaultDbPath = "./data"
+
 // DbFlags are the flags we append for setting shell connection arguments.
 var DbFlags []cli.Flag
 
@@ -20,10 +29,23 @@ var cliDbArgs = struct {
 	// DbType is the DB type to use.
 	DbType string
 	// DbPath is the path to store data in.
+	// If empty, defaultDbPath is used for db types other than inmem.
 	DbPath string
+	// BoltBucket is the bucket to use with the bolt db type.
+	BoltBucket string
+	// InmemSaveInterval is the interval between saves with the inmem db type.
+	InmemSaveInterval time.Duration
+	// Badger configures the badger db type.
+	// The path is set from DbPath.
+	Badger dbadger.Config
 }{
-	DbType: "badger",
-	DbPath: "./data",
+	DbType:            "badger",
+	BoltBucket:        "objstore",
+	InmemSaveInterval: time.Minute,
+	Badger: dbadger.Config{
+		GCInterval:     dbadger.DefaultGCInterval,
+		GCDiscardRatio: dbadger.DefaultGCDiscardRatio,
+	},
 }
 
 // Ctor builds a database implementation.
@@ -31,26 +53,62 @@ type Ctor func(path string) (db.Db, error)
 
 var cliDbImpls = map[string]Ctor{
 	"inmem": func(path string) (db.Db, error) {
-		return inmem.NewInmemDb(), nil
+		if path == "" {
+			return inmem.NewInmemDb(), nil
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+
+		fdb, err := inmem.OpenFileDb(path, cliDbArgs.InmemSaveInterval)
+		if err != nil {
+			return nil, err
+		}
+
+		return fdb, nil
 	},
 	"badger": func(path string) (db.Db, error) {
-		if err := os.MkdirAll(path, 0755); err != nil {
+		conf := cliDbArgs.Badger
+		if !conf.InMemory && !conf.ReadOnly {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return nil, err
+			}
+		}
+
+		conf.Path = path
+		bdb, err := dbadger.OpenBadgerDB(conf)
+		if err != nil {
+			return nil, err
+		}
+
+		return bdb, nil
+	},
+	"bolt": func(path string) (db.Db, error) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 			return nil, err
 		}
 
-		badgerOpts := badger.DefaultOptions
-		badgerOpts.Dir = path
-		badgerOpts.ValueDir = path
-		bdb, err := badger.Open(badgerOpts)
+		bdb, err := bbolt.Open(path, 0644, nil)
 		if err != nil {
 			return nil, err
 		}
 
-		return dbadger.NewBadgerDB(bdb), nil
+		d, err := dbolt.NewBoltDB(bdb, []byte(cliDbArgs.BoltBucket))
+		if err != nil {
+			bdb.Close()
+			return nil, err
+		}
+
+		return d, nil
+	},
+	"fs": func(path string) (db.Db, error) {
+		return fsdb.NewFsDB(path)
 	},
 }
 
 // RegisterCtor registers a command-line database constructor.
+// Should be called from init, for example as in the clisqlite package.
 func RegisterCtor(id string, ctor Ctor) {
 	cliDbImpls[id] = ctor
 }
@@ -60,22 +118,80 @@ func init() {
 		DbFlags,
 		cli.StringFlag{
 			Name:        "db-type",
-			Usage:       "The DB type to use, badgerdb is the only supported value.",
+			Usage:       "The DB type to use: badger, bolt, fs, inmem, or sqlite if the clisqlite package is imported.",
 			EnvVar:      "DB_TYPE",
 			Value:       cliDbArgs.DbType,
 			Destination: &cliDbArgs.DbType,
 		},
 		cli.StringFlag{
 			Name:        "db-path",
-			Usage:       "The path to store data in.",
+			Usage:       "The path to store data in, " + defaultDbPath + " if unset. With the inmem DB type, the snapshot file, data is not persisted if unset.",
 			EnvVar:      "DB_PATH",
-			Value:       cliDbArgs.DbPath,
 			Destination: &cliDbArgs.DbPath,
 		},
+		cli.StringFlag{
+			Name:        "db-bolt-bucket",
+			Usage:       "The bucket to store data in with the bolt DB type.",
+			EnvVar:      "DB_BOLT_BUCKET",
+			Value:       cliDbArgs.BoltBucket,
+			Destination: &cliDbArgs.BoltBucket,
+		},
+		cli.DurationFlag{
+			Name:        "db-inmem-save-interval",
+			Usage:       "The interval between snapshot saves with the inmem DB type, 0 to only save on exit.",
+			EnvVar:      "DB_INMEM_SAVE_INTERVAL",
+			Value:       cliDbArgs.InmemSaveInterval,
+			Destination: &cliDbArgs.InmemSaveInterval,
+		},
+		cli.BoolFlag{
+			Name:        "db-badger-sync-writes",
+			Usage:       "Sync writes to disk before acknowledging them with the badger DB type.",
+			EnvVar:      "DB_BADGER_SYNC_WRITES",
+			Destination: &cliDbArgs.Badger.SyncWrites,
+		},
+		cli.BoolFlag{
+			Name:        "db-badger-in-memory",
+			Usage:       "Store data in a temporary directory removed on exit with the badger DB type.",
+			EnvVar:      "DB_BADGER_IN_MEMORY",
+			Destination: &cliDbArgs.Badger.InMemory,
+		},
+		cli.BoolFlag{
+			Name:        "db-badger-read-only",
+			Usage:       "Open the database read-only with the badger DB type.",
+			EnvVar:      "DB_BADGER_READ_ONLY",
+			Destination: &cliDbArgs.Badger.ReadOnly,
+		},
+		cli.Int64Flag{
+			Name:        "db-badger-max-table-size",
+			Usage:       "The maximum size of LSM tables in bytes with the badger DB type, 0 for the default.",
+			EnvVar:      "DB_BADGER_MAX_TABLE_SIZE",
+			Destination: &cliDbArgs.Badger.MaxTableSize,
+		},
+		cli.Int64Flag{
+			Name:        "db-badger-value-log-file-size",
+			Usage:       "The maximum size of value log files in bytes with the badger DB type, 0 for the default.",
+			EnvVar:      "DB_BADGER_VALUE_LOG_FILE_SIZE",
+			Destination: &cliDbArgs.Badger.ValueLogFileSize,
+		},
+		cli.DurationFlag{
+			Name:        "db-badger-gc-interval",
+			Usage:       "The interval between value log GC runs with the badger DB type, negative to disable.",
+			EnvVar:      "DB_BADGER_GC_INTERVAL",
+			Value:       cliDbArgs.Badger.GCInterval,
+			Destination: &cliDbArgs.Badger.GCInterval,
+		},
+		cli.Float64Flag{
+			Name:        "db-badger-gc-discard-ratio",
+			Usage:       "The fraction of a value log file which must be discardable to rewrite it with the badger DB type.",
+			EnvVar:      "DB_BADGER_GC_DISCARD_RATIO",
+			Value:       cliDbArgs.Badger.GCDiscardRatio,
+			Destination: &cliDbArgs.Badger.GCDiscardRatio,
+		},
 	)
 }
 
 // BuildCliDb builds the db from CLI args.
+// The db should be closed with db.Close when it is no longer used.
 func BuildCliDb(log *logrus.Entry) (db.Db, error) {
 	dbType := cliDbArgs.DbType
 	ctor, ok := cliDbImpls[dbType]
@@ -83,5 +199,10 @@ func BuildCliDb(log *logrus.Entry) (db.Db, error) {
 		return nil, errors.Errorf("unsupported db type: %s", dbType)
 	}
 
-	return ctor(cliDbArgs.DbPath)
+	path := cliDbArgs.DbPath
+	if path == "" && dbType != "inmem" {
+		path = defaultDbPath
+	}
+
+	return ctor(path)
 }
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var cliListArgs = struct {
+	// Prefix is the key prefix to list.
+	Prefix string
+	// StartAfter is the key to start listing after.
+	StartAfter string
+	// Limit is the maximum number of keys to list.
+	Limit int
+}{}
+
+// DbCommands are commands for inspecting the database configured by DbFlags.
+var DbCommands = []cli.Command{
+	{
+		Name:  "list-keys",
+		Usage: "List keys in the database, a page at a time.",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "prefix",
+				Usage:       "The key prefix to list.",
+				Destination: &cliListArgs.Prefix,
+			},
+			cli.StringFlag{
+				Name:        "start-after",
+				Usage:       "The key to start listing after, quoted as printed by a previous page.",
+				Destination: &cliListArgs.StartAfter,
+			},
+			cli.IntFlag{
+				Name:        "limit",
+				Usage:       "The maximum number of keys to list, 0 for all.",
+				Value:       db.DefaultPageSize,
+				Destination: &cliListArgs.Limit,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return runListKeys(logrus.NewEntry(logrus.StandardLogger()))
+		},
+	},
+}
+
+// errListLimit stops listing at the limit.
+var errListLimit = errors.New("list limit reached")
+
+// runListKeys lists keys in the database, printing one Go-quoted key per line.
+func runListKeys(le *logrus.Entry) error {
+	ctx := context.Background()
+	var cursor []byte
+	if cliListArgs.StartAfter != "" {
+		startAfter, err := strconv.Unquote(cliListArgs.StartAfter)
+		if err != nil {
+			return errors.Wrap(err, "start-after must be a quoted key as printed by list-keys")
+		}
+		cursor = []byte(startAfter)
+	}
+
+	d, err := BuildCliDb(le)
+	if err != nil {
+		return err
+	}
+	defer db.Close(d)
+
+	// list one more key than the limit to check if more keys follow
+	limit := cliListArgs.Limit
+	pageSize := db.DefaultPageSize
+	if limit > 0 && limit < pageSize {
+		pageSize = limit + 1
+	}
+
+	var last []byte
+	var listed int
+	err = db.ForEachPageAfter(ctx, d, []byte(cliListArgs.Prefix), cursor, pageSize, func(keys [][]byte) error {
+		for _, key := range keys {
+			if limit > 0 && listed == limit {
+				le.Infof(
+					"more keys follow, continue with --start-after %s",
+					shellQuote(strconv.Quote(string(last))),
+				)
+				return errListLimit
+			}
+			fmt.Println(strconv.Quote(string(key)))
+			last = key
+			listed++
+		}
+		return nil
+	})
+	if err == errListLimit {
+		return nil
+	}
+	return err
+}
+
+// shellQuote quotes a string for a posix shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,44 @@
+// Package clisqlite registers the sqlite db type with the db cli.
+// It is a separate package as the sqlite driver requires cgo, import it to
+// enable the sqlite db type:
+//
+//	import _ "github.com/aperturerobotics/objstore/db/cli/clisqlite"
+package clisqlite
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/cli"
+	"github.com/aperturerobotics/objstore/db/sqlite"
+
+	// registers the sqlite3 database/sql driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteTable is the table used by the sqlite db type.
+const sqliteTable = "objstore"
+
+func init() {
+	cli.RegisterCtor("sqlite", func(path string) (db.Db, error) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+
+		sdb, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+		if err != nil {
+			return nil, err
+		}
+
+		d, err := sqlite.NewSqliteDB(context.Background(), sdb, sqliteTable)
+		if err != nil {
+			sdb.Close()
+			return nil, err
+		}
+
+		return d, nil
+	})
+}
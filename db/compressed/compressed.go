@@ -0,0 +1,267 @@
+package compressed
+
+import (
+	"context"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// Header bytes prepended to stored values.
+// Each is a protobuf tag with the invalid wire type 7, so they never begin an
+// encoded protobuf message. Values without a header byte are stored as-is,
+// which keeps protobuf values written before compression was enabled
+// readable. Other legacy values beginning with a header byte are misread.
+const (
+	// headerRaw marks an uncompressed value which begins with a header byte.
+	headerRaw byte = 0x07
+	// headerSnappy marks a snappy compressed value.
+	headerSnappy byte = 0x0f
+	// headerZstd marks a zstd compressed value.
+	headerZstd byte = 0x17
+)
+
+// DefaultThreshold is the default minimum size of values to compress.
+const DefaultThreshold = 64
+
+// Algorithm is a compression algorithm.
+type Algorithm int
+
+const (
+	// Snappy selects snappy compression.
+	Snappy Algorithm = iota
+	// Zstd selects zstd compression.
+	Zstd
+)
+
+// Config configures a compressed database.
+type Config struct {
+	// Algorithm is the algorithm used to compress new values.
+	// Values compressed with any algorithm can be read.
+	Algorithm Algorithm
+	// Threshold is the size in bytes below which values are stored as-is.
+	// If zero, DefaultThreshold is used.
+	Threshold int
+}
+
+// CompressedDb compresses values going in and out of a db.
+// Values written to the db before wrapping it remain readable only if they
+// are encoded protobuf messages, or do not begin with a header byte.
+// CompressedDb must be closed to release the zstd encoder and decoder.
+type CompressedDb struct {
+	db        db.Db
+	algorithm Algorithm
+	threshold int
+
+	zenc *zstd.Encoder
+	zdec *zstd.Decoder
+}
+
+// NewCompressedDb builds a new compressed database wrapping d.
+func NewCompressedDb(d db.Db, conf Config) (*CompressedDb, error) {
+	switch conf.Algorithm {
+	case Snappy, Zstd:
+	default:
+		return nil, errors.Errorf("unknown compression algorithm: %d", conf.Algorithm)
+	}
+
+	zenc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	zdec, err := zstd.NewReader(nil)
+	if err != nil {
+		_ = zenc.Close()
+		return nil, err
+	}
+
+	threshold := conf.Threshold
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+
+	return &CompressedDb{
+		db:        d,
+		algorithm: conf.Algorithm,
+		threshold: threshold,
+		zenc:      zenc,
+		zdec:      zdec,
+	}, nil
+}
+
+// isHeader checks if a byte is a header byte.
+func isHeader(b byte) bool {
+	return b == headerRaw || b == headerSnappy || b == headerZstd
+}
+
+// encode encodes a value for storage.
+func (c *CompressedDb) encode(val []byte) []byte {
+	if len(val) >= c.threshold {
+		var out []byte
+		switch c.algorithm {
+		case Zstd:
+			out = c.zenc.EncodeAll(val, []byte{headerZstd})
+		default:
+			out = make([]byte, 1+snappy.MaxEncodedLen(len(val)))
+			out[0] = headerSnappy
+			out = out[:1+len(snappy.Encode(out[1:], val))]
+		}
+
+		// only keep the compressed value if it is smaller
+		if len(out) < len(val) {
+			return out
+		}
+	}
+
+	if len(val) != 0 && isHeader(val[0]) {
+		out := make([]byte, len(val)+1)
+		out[0] = headerRaw
+		copy(out[1:], val)
+		return out
+	}
+
+	return val
+}
+
+// decode decodes a stored value.
+func (c *CompressedDb) decode(data []byte) ([]byte, error) {
+	if len(data) == 0 || !isHeader(data[0]) {
+		return data, nil
+	}
+
+	switch data[0] {
+	case headerZstd:
+		return c.zdec.DecodeAll(data[1:], nil)
+	case headerSnappy:
+		return snappy.Decode(nil, data[1:])
+	default:
+		return data[1:], nil
+	}
+}
+
+// Get retrieves an object from the database.
+func (c *CompressedDb) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	data, found, err := c.db.Get(ctx, key)
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	val, err := c.decode(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return val, true, nil
+}
+
+// Set sets an object in the database.
+func (c *CompressedDb) Set(ctx context.Context, key []byte, val []byte) error {
+	return c.db.Set(ctx, key, c.encode(val))
+}
+
+// SetWithTTL sets an object in the database with a ttl.
+func (c *CompressedDb) SetWithTTL(ctx context.Context, key []byte, val []byte, ttl time.Duration) error {
+	return db.SetWithTTL(ctx, c.db, key, c.encode(val), ttl)
+}
+
+// GetTTL returns the remaining ttl of a key.
+func (c *CompressedDb) GetTTL(ctx context.Context, key []byte) (time.Duration, bool, error) {
+	return db.GetTTL(ctx, c.db, key)
+}
+
+// List returns a list of keys with the specified prefix.
+func (c *CompressedDb) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	return c.db.List(ctx, prefix)
+}
+
+// Delete clears a set of keys from the db.
+func (c *CompressedDb) Delete(ctx context.Context, keys ...[]byte) error {
+	return c.db.Delete(ctx, keys...)
+}
+
+// NewIterator builds a new iterator, decoding values from the underlying db.
+func (c *CompressedDb) NewIterator(ctx context.Context, opts db.IteratorOpts) (db.Iterator, error) {
+	it, err := db.NewIterator(ctx, c.db, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compressedIterator{Iterator: it, c: c}, nil
+}
+
+// compressedIterator decodes values returned by an iterator.
+type compressedIterator struct {
+	db.Iterator
+	c *CompressedDb
+}
+
+// Value returns a copy of the value at the current position.
+func (i *compressedIterator) Value() ([]byte, error) {
+	data, err := i.Iterator.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	return i.c.decode(data)
+}
+
+// NewTxn builds a new transaction, compressing values in a transaction
+// against the underlying db.
+func (c *CompressedDb) NewTxn(ctx context.Context, write bool) (db.Txn, error) {
+	txn, err := db.NewTxn(ctx, c.db, write)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compressedTxn{Txn: txn, c: c}, nil
+}
+
+// compressedTxn compresses values going in and out of a transaction.
+type compressedTxn struct {
+	db.Txn
+	c *CompressedDb
+}
+
+// Get retrieves an object from the transaction.
+func (t *compressedTxn) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	data, found, err := t.Txn.Get(ctx, key)
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	val, err := t.c.decode(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return val, true, nil
+}
+
+// Set sets an object in the transaction.
+func (t *compressedTxn) Set(ctx context.Context, key []byte, val []byte) error {
+	return t.Txn.Set(ctx, key, t.c.encode(val))
+}
+
+// Close releases the zstd encoder and decoder, and closes the underlying db.
+func (c *CompressedDb) Close() error {
+	c.zdec.Close()
+	err := c.zenc.Close()
+	if cerr := db.Close(c.db); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// _ are type assertions
+var (
+	_ db.Db        = &CompressedDb{}
+	_ db.Batcher   = &CompressedDb{}
+	_ db.Closer    = &CompressedDb{}
+	_ db.Iterable  = &CompressedDb{}
+	_ db.TTLGetter = &CompressedDb{}
+	_ db.TTLSetter = &CompressedDb{}
+)
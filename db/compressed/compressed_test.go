@@ -0,0 +1,104 @@
+package compressed
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/dbtest"
+	"github.com/aperturerobotics/objstore/db/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance runs the db conformance suite.
+func TestConformance(t *testing.T) {
+	for name, alg := range map[string]Algorithm{"Snappy": Snappy, "Zstd": Zstd} {
+		alg := alg
+		t.Run(name, func(t *testing.T) {
+			dbtest.RunConformance(t, func() db.Db {
+				d, err := NewCompressedDb(inmem.NewInmemDb(), Config{Algorithm: alg, Threshold: 1})
+				require.NoError(t, err)
+				return d
+			})
+		})
+	}
+}
+
+// TestCompressed tests values are compressed and old values remain readable.
+func TestCompressed(t *testing.T) {
+	ctx := context.Background()
+	under := inmem.NewInmemDb()
+
+	// values written before compression was enabled
+	legacy := []byte{0x0a, 0x03, 'f', 'o', 'o'}
+	require.NoError(t, under.Set(ctx, []byte("/legacy"), legacy))
+
+	for _, alg := range []Algorithm{Snappy, Zstd} {
+		d, err := NewCompressedDb(under, Config{Algorithm: alg})
+		require.NoError(t, err)
+
+		big := bytes.Repeat([]byte("compressible "), 100)
+		require.NoError(t, d.Set(ctx, []byte("/big"), big))
+		stored, _, err := under.Get(ctx, []byte("/big"))
+		require.NoError(t, err)
+		require.True(t, len(stored) < len(big))
+
+		// small values are stored as-is
+		small := []byte("small")
+		require.NoError(t, d.Set(ctx, []byte("/small"), small))
+		stored, _, err = under.Get(ctx, []byte("/small"))
+		require.NoError(t, err)
+		require.Equal(t, small, stored)
+
+		// small values beginning with a header byte are escaped
+		header := []byte{headerZstd, 1, 2}
+		require.NoError(t, d.Set(ctx, []byte("/header"), header))
+
+		for key, expected := range map[string][]byte{
+			"/legacy": legacy,
+			"/big":    big,
+			"/small":  small,
+			"/header": header,
+		} {
+			val, found, err := d.Get(ctx, []byte(key))
+			require.NoError(t, err)
+			require.True(t, found)
+			require.Equal(t, expected, val)
+		}
+		require.NoError(t, d.Close())
+	}
+}
+
+// TestTxnIterator tests values are compressed in transactions and decoded by
+// iterators.
+func TestTxnIterator(t *testing.T) {
+	ctx := context.Background()
+	under := inmem.NewInmemDb()
+	d, err := NewCompressedDb(under, Config{Algorithm: Zstd})
+	require.NoError(t, err)
+	defer d.Close()
+
+	big := bytes.Repeat([]byte("compressible "), 100)
+	txn, err := d.NewTxn(ctx, true)
+	require.NoError(t, err)
+	defer txn.Discard()
+	require.NoError(t, txn.Set(ctx, []byte("/big"), big))
+	val, found, err := txn.Get(ctx, []byte("/big"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, big, val)
+	require.NoError(t, txn.Commit(ctx))
+
+	stored, _, err := under.Get(ctx, []byte("/big"))
+	require.NoError(t, err)
+	require.True(t, len(stored) < len(big))
+
+	it, err := d.NewIterator(ctx, db.IteratorOpts{Prefix: []byte("/")})
+	require.NoError(t, err)
+	defer it.Close()
+	require.True(t, it.Valid())
+	val, err = it.Value()
+	require.NoError(t, err)
+	require.Equal(t, big, val)
+}
@@ -4,17 +4,23 @@ import (
 	"context"
 )
 
-// Db is an implementation of a key-value database.
-type Db interface {
+// ReadOnlyDb is a read-only view of a key-value database.
+type ReadOnlyDb interface {
 	// Get retrieves an object from the database.
 	// Not found should return nil, false, nil
 	// Found should return data, true, nil
 	// Error should return nil, false, err
 	Get(ctx context.Context, key []byte) ([]byte, bool, error)
-	// Set sets an object in the database.
-	Set(ctx context.Context, key []byte, val []byte) error
 	// List returns a list of keys with the specified prefix.
 	List(ctx context.Context, prefix []byte) ([][]byte, error)
+}
+
+// Db is an implementation of a key-value database.
+type Db interface {
+	ReadOnlyDb
+
+	// Set sets an object in the database.
+	Set(ctx context.Context, key []byte, val []byte) error
 	// Delete clears a set of keys from the db.
 	// Not found should not return an error.
 	Delete(ctx context.Context, keys ...[]byte) error
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrCASNotSupported is returned when making a conditional write to a database
+// without support for them.
+var ErrCASNotSupported = errors.New("database does not support compare-and-swap")
+
+// CompareAndSwapper is a database which supports atomic conditional writes.
+type CompareAndSwapper interface {
+	// CompareAndSwap sets the key to val if the current value equals old.
+	// A nil old matches a key which does not exist, an empty non-nil old
+	// matches an empty value. Returns if the value was set.
+	CompareAndSwap(ctx context.Context, key []byte, old, val []byte) (bool, error)
+	// SetIfNotExists sets the key to val if the key does not exist.
+	// Returns if the value was set.
+	SetIfNotExists(ctx context.Context, key []byte, val []byte) (bool, error)
+}
+
+// CompareAndSwap sets the key to val if the current value equals old.
+// Returns ErrCASNotSupported if the database does not implement CompareAndSwapper.
+func CompareAndSwap(ctx context.Context, d Db, key []byte, old, val []byte) (bool, error) {
+	cs, ok := d.(CompareAndSwapper)
+	if !ok {
+		return false, ErrCASNotSupported
+	}
+
+	return cs.CompareAndSwap(ctx, key, old, val)
+}
+
+// SetIfNotExists sets the key to val if the key does not exist.
+// Returns ErrCASNotSupported if the database does not implement CompareAndSwapper.
+func SetIfNotExists(ctx context.Context, d Db, key []byte, val []byte) (bool, error) {
+	cs, ok := d.(CompareAndSwapper)
+	if !ok {
+		return false, ErrCASNotSupported
+	}
+
+	return cs.SetIfNotExists(ctx, key, val)
+}
@@ -0,0 +1,25 @@
+package db
+
+// Closer is a database which holds resources that must be released.
+type Closer interface {
+	// Close releases the resources held by the database.
+	// The database must not be used after Close.
+	Close() error
+}
+
+// ClosableDb is a database which must be closed when it is no longer used.
+type ClosableDb interface {
+	Db
+	Closer
+}
+
+// Close closes the database if it implements Closer.
+// Databases without resources to release are left as-is.
+func Close(d ReadOnlyDb) error {
+	c, ok := d.(Closer)
+	if !ok {
+		return nil
+	}
+
+	return c.Close()
+}
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"context"
+)
+
+// PrefixDropper is a database which can delete all keys with a prefix.
+type PrefixDropper interface {
+	// DropPrefix deletes all keys with the prefix.
+	// An empty prefix deletes all keys.
+	DropPrefix(ctx context.Context, prefix []byte) error
+}
+
+// DropPrefix deletes all keys with the prefix.
+// If the database does not implement PrefixDropper, the keys are listed and
+// deleted a page at a time with ForEachPage.
+func DropPrefix(ctx context.Context, d Db, prefix []byte) error {
+	if pd, ok := d.(PrefixDropper); ok {
+		return pd.DropPrefix(ctx, prefix)
+	}
+
+	return ForEachPage(ctx, d, prefix, DefaultPageSize, func(keys [][]byte) error {
+		return d.Delete(ctx, keys...)
+	})
+}
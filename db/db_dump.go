@@ -0,0 +1,333 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// DumpVersion is the current dump format version.
+const DumpVersion = 1
+
+// maxDumpFrameSize is the maximum size of a single frame in a dump.
+const maxDumpFrameSize = 1 << 30
+
+// restoreBatchSize is the number of records written per transaction when
+// restoring a dump.
+const restoreBatchSize = 256
+
+// ErrDumpTruncated is returned when a dump ends before the trailer.
+var ErrDumpTruncated = errors.New("dump truncated")
+
+// ErrDumpChecksum is returned when the dump checksum does not match.
+var ErrDumpChecksum = errors.New("dump checksum mismatch")
+
+// Dump writes all keys with the prefix and their values to w.
+//
+// The dump is a stream of frames, each a uvarint length followed by an encoded
+// protobuf message: a DumpHeader, a DumpEntry with a Record for each key in
+// sorted order, and a DumpEntry with a Trailer containing the record count and
+// the SHA-256 checksum of all preceding bytes.
+//
+// If the dumped database is a TTLGetter which supports ttls, records of keys
+// with a ttl contain their expiry time.
+//
+// If d is a Snapshotter, the dump is taken from a snapshot, so writes can
+// continue while dumping. If the snapshot returns ErrSnapshotNotSupported, as
+// wrappers do over databases without snapshots, d is dumped directly.
+func Dump(ctx context.Context, d ReadOnlyDb, prefix []byte, w io.Writer) error {
+	if s, ok := d.(Snapshotter); ok {
+		snap, release, err := s.Snapshot(ctx)
+		switch {
+		case err == ErrSnapshotNotSupported:
+		case err != nil:
+			return err
+		default:
+			defer release()
+			d = snap
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	h := sha256.New()
+	hw := io.MultiWriter(bw, h)
+
+	if err := writeDumpFrame(hw, &DumpHeader{Version: DumpVersion, Prefix: prefix}); err != nil {
+		return err
+	}
+
+	it, err := NewIterator(ctx, d, IteratorOpts{Prefix: prefix})
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	ttlGetter, _ := d.(TTLGetter)
+	var count uint64
+	for ; it.Valid(); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		val, err := it.Value()
+		if err != nil {
+			return err
+		}
+
+		record := &DumpRecord{Key: it.Key(), Value: val}
+		if ttlGetter != nil {
+			ttl, ok, err := ttlGetter.GetTTL(ctx, record.Key)
+			switch {
+			case err == ErrTTLNotSupported:
+				// wrappers over databases without ttls
+				ttlGetter = nil
+			case err != nil:
+				return err
+			case !ok:
+				// expired while dumping
+				continue
+			case ttl > 0:
+				record.ExpiresAt = time.Now().Add(ttl).UnixNano()
+			}
+		}
+
+		if err := writeDumpFrame(hw, &DumpEntry{Record: record}); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	if err := writeDumpFrame(bw, &DumpEntry{
+		Trailer: &DumpTrailer{Count: count, Checksum: h.Sum(nil)},
+	}); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// Restore reads a dump written by Dump from r, writing the records to d.
+//
+// The dump is read twice: the checksum is verified before any record is
+// written, so a corrupt or truncated dump leaves d untouched. If r is not an
+// io.Seeker, the dump is first copied to a temporary file. Records are then
+// written in batches, if a write fails, batches written before the failure
+// remain. Existing keys which are not in the dump are not removed.
+//
+// Records with an expiry time are set with their remaining ttl after the batch
+// containing them is committed, records which have since expired are skipped.
+// Returns ErrTTLNotSupported before writing if the dump contains records with
+// an expiry time and d does not implement TTLSetter.
+func Restore(ctx context.Context, d Db, r io.Reader) error {
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		f, err := ioutil.TempFile("", "objstore-restore-")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+
+		if _, err := io.Copy(f, r); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		rs = f
+	}
+
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	var expiring bool
+	if err := readDump(ctx, rs, func(record *DumpRecord) error {
+		expiring = expiring || record.GetExpiresAt() != 0
+		return nil
+	}); err != nil {
+		return err
+	}
+	if _, ok := d.(TTLSetter); expiring && !ok {
+		return ErrTTLNotSupported
+	}
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	var txn Txn
+	defer func() {
+		if txn != nil {
+			txn.Discard()
+		}
+	}()
+
+	// pending are the records with an expiry time in the current batch
+	var pending []*DumpRecord
+	flush := func() error {
+		if txn != nil {
+			err := txn.Commit(ctx)
+			txn.Discard()
+			txn = nil
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, record := range pending {
+			ttl := time.Until(time.Unix(0, record.GetExpiresAt()))
+			if ttl <= 0 {
+				continue
+			}
+			if err := SetWithTTL(ctx, d, record.GetKey(), recordValue(record), ttl); err != nil {
+				return err
+			}
+		}
+		pending = nil
+		return nil
+	}
+
+	var count int
+	err = readDump(ctx, rs, func(record *DumpRecord) error {
+		if record.GetExpiresAt() != 0 {
+			pending = append(pending, record)
+		} else {
+			if txn == nil {
+				var err error
+				txn, err = NewTxn(ctx, d, true)
+				if err != nil {
+					return err
+				}
+			}
+			if err := txn.Set(ctx, record.GetKey(), recordValue(record)); err != nil {
+				return err
+			}
+		}
+
+		count++
+		if count%restoreBatchSize != 0 {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+// recordValue returns the value of a record, an empty slice if it is nil.
+func recordValue(record *DumpRecord) []byte {
+	if val := record.GetValue(); val != nil {
+		return val
+	}
+	return []byte{}
+}
+
+// readDump reads a dump, calling cb with each record if set.
+// Returns an error if the dump is corrupt or truncated, after calling cb with
+// the records read before the error.
+func readDump(ctx context.Context, r io.Reader, cb func(record *DumpRecord) error) error {
+	br := bufio.NewReader(r)
+	h := sha256.New()
+
+	header := &DumpHeader{}
+	if err := readDumpFrame(br, h, header); err != nil {
+		return err
+	}
+	if header.GetVersion() != DumpVersion {
+		return fmt.Errorf("unsupported dump version: %d", header.GetVersion())
+	}
+
+	var count uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// the checksum covers everything before the trailer
+		sum := h.Sum(nil)
+		entry := &DumpEntry{}
+		if err := readDumpFrame(br, h, entry); err != nil {
+			return err
+		}
+
+		if trailer := entry.GetTrailer(); trailer != nil {
+			if trailer.GetCount() != count || !bytes.Equal(trailer.GetChecksum(), sum) {
+				return ErrDumpChecksum
+			}
+			return nil
+		}
+
+		record := entry.GetRecord()
+		if record == nil {
+			return errors.New("dump entry has no record or trailer")
+		}
+		if cb != nil {
+			if err := cb(record); err != nil {
+				return err
+			}
+		}
+		count++
+	}
+}
+
+// writeDumpFrame writes a length-delimited message.
+func writeDumpFrame(w io.Writer, msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(data)))
+	if _, err := w.Write(lenBuf[:n]); err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// readDumpFrame reads a length-delimited message, writing the frame to h.
+func readDumpFrame(r *bufio.Reader, h io.Writer, msg proto.Message) error {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrDumpTruncated
+		}
+		return err
+	}
+	if size > maxDumpFrameSize {
+		return fmt.Errorf("dump frame too large: %d bytes", size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrDumpTruncated
+		}
+		return err
+	}
+
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], size)
+	_, _ = h.Write(lenBuf[:n])
+	_, _ = h.Write(data)
+
+	return proto.Unmarshal(data, msg)
+}
@@ -0,0 +1,120 @@
+package db_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDumpRestore tests dumping and restoring a database.
+func TestDumpRestore(t *testing.T) {
+	ctx := context.Background()
+	src := inmem.NewInmemDb()
+	for i := 0; i < 600; i++ {
+		key := []byte(fmt.Sprintf("/a/%04d", i))
+		require.NoError(t, src.Set(ctx, key, key))
+	}
+	require.NoError(t, src.Set(ctx, []byte("/a/empty"), nil))
+	require.NoError(t, src.Set(ctx, []byte("/b"), []byte("b")))
+
+	var buf bytes.Buffer
+	require.NoError(t, db.Dump(ctx, src, []byte("/a/"), &buf))
+	dump := buf.Bytes()
+
+	dst := inmem.NewInmemDb()
+	require.NoError(t, db.Restore(ctx, dst, bytes.NewReader(dump)))
+	keys, err := dst.List(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, keys, 601)
+	val, found, err := dst.Get(ctx, []byte("/a/0599"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("/a/0599"), val)
+	val, found, err = dst.Get(ctx, []byte("/a/empty"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Len(t, val, 0)
+	_, found, err = dst.Get(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// truncated and corrupted dumps are not written, whether or not the reader
+	// can seek
+	corrupt := append([]byte(nil), dump...)
+	idx := bytes.Index(corrupt, []byte("/a/0300"))
+	corrupt[idx+6] = '1'
+	for _, seek := range []bool{true, false} {
+		var r io.Reader = bytes.NewReader(dump[:len(dump)-10])
+		if !seek {
+			r = bytes.NewBuffer(dump[:len(dump)-10])
+		}
+		dst := inmem.NewInmemDb()
+		require.Equal(t, db.ErrDumpTruncated, db.Restore(ctx, dst, r))
+		keys, err := dst.List(ctx, nil)
+		require.NoError(t, err)
+		require.Empty(t, keys)
+
+		r = bytes.NewReader(corrupt)
+		if !seek {
+			r = bytes.NewBuffer(corrupt)
+		}
+		require.Equal(t, db.ErrDumpChecksum, db.Restore(ctx, dst, r))
+		keys, err = dst.List(ctx, nil)
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	}
+}
+
+// TestDumpNoSnapshot tests dumping through a prefix over a database without
+// snapshots.
+func TestDumpNoSnapshot(t *testing.T) {
+	ctx := context.Background()
+	src := struct{ db.Db }{inmem.NewInmemDb()}
+	pd := db.WithPrefix(src, []byte("/p"))
+	require.NoError(t, pd.Set(ctx, []byte("/a"), []byte("a")))
+	require.NoError(t, pd.Set(ctx, []byte("/b"), []byte("b")))
+	_, _, err := db.Snapshot(ctx, pd)
+	require.Equal(t, db.ErrSnapshotNotSupported, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, db.Dump(ctx, pd, nil, &buf))
+
+	dst := inmem.NewInmemDb()
+	require.NoError(t, db.Restore(ctx, dst, &buf))
+	keys, err := dst.List(ctx, nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, [][]byte{[]byte("/a"), []byte("/b")}, keys)
+}
+
+// TestRestoreTTL tests restoring keys with a ttl.
+func TestRestoreTTL(t *testing.T) {
+	ctx := context.Background()
+	src := inmem.NewInmemDb()
+	require.NoError(t, src.Set(ctx, []byte("/a"), []byte("a")))
+	require.NoError(t, db.SetWithTTL(ctx, src, []byte("/b"), []byte("b"), time.Minute))
+
+	var buf bytes.Buffer
+	require.NoError(t, db.Dump(ctx, src, nil, &buf))
+	data := buf.Bytes()
+
+	dst := inmem.NewInmemDb()
+	require.NoError(t, db.Restore(ctx, dst, bytes.NewReader(data)))
+	ttl, found, err := db.GetTTL(ctx, dst, []byte("/b"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, ttl > 0 && ttl <= time.Minute, "ttl: %v", ttl)
+
+	// databases without ttl support are left untouched
+	noTTL := &listCountingDb{Db: inmem.NewInmemDb()}
+	require.Equal(t, db.ErrTTLNotSupported, db.Restore(ctx, noTTL, bytes.NewReader(data)))
+	keys, err := noTTL.List(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
@@ -0,0 +1,228 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"sort"
+)
+
+// IteratorOpts are options for building an iterator.
+type IteratorOpts struct {
+	// Prefix restricts the iterator to keys with the prefix.
+	Prefix []byte
+	// Start is the inclusive lower bound of the range, if set.
+	Start []byte
+	// End is the exclusive upper bound of the range, if set.
+	End []byte
+	// Limit is the maximum number of entries to visit, if non-zero.
+	// The count is reset by Seek.
+	Limit int
+	// Reverse iterates in descending key order.
+	Reverse bool
+}
+
+// Bounds returns the effective [lower, upper) bounds of the options.
+// A nil upper bound indicates the range is unbounded above.
+func (o *IteratorOpts) Bounds() (lower []byte, upper []byte) {
+	lower = o.Prefix
+	if bytes.Compare(o.Start, lower) > 0 {
+		lower = o.Start
+	}
+
+	upper = PrefixEnd(o.Prefix)
+	if o.End != nil && (upper == nil || bytes.Compare(o.End, upper) < 0) {
+		upper = o.End
+	}
+
+	return lower, upper
+}
+
+// Iterator iterates over a range of keys in a database.
+//
+// Usage:
+//
+//	it, err := db.NewIterator(ctx, d, db.IteratorOpts{Prefix: prefix})
+//	if err != nil { ... }
+//	defer it.Close()
+//	for ; it.Valid(); it.Next() { ... }
+type Iterator interface {
+	// Seek moves the iterator to the first key >= key, or the last key <= key
+	// if iterating in reverse. The key is clamped to the iterator bounds.
+	// A nil key seeks to the beginning of the range.
+	Seek(key []byte)
+	// Next advances the iterator.
+	Next()
+	// Valid indicates the iterator is positioned at an entry.
+	Valid() bool
+	// Key returns the key at the current position.
+	// The slice is only valid until the next call to Next or Seek.
+	Key() []byte
+	// Value returns a copy of the value at the current position.
+	Value() ([]byte, error)
+	// Err returns any error encountered during iteration.
+	Err() error
+	// Close releases the iterator.
+	Close() error
+}
+
+// Iterable is a database which can natively iterate over a range of keys.
+type Iterable interface {
+	// NewIterator builds a new iterator positioned at the start of the range.
+	NewIterator(ctx context.Context, opts IteratorOpts) (Iterator, error)
+}
+
+// NewIterator builds a new iterator over a database.
+// If the database does not implement Iterable, the keys are listed with List
+// and values are fetched with Get as they are visited.
+func NewIterator(ctx context.Context, d ReadOnlyDb, opts IteratorOpts) (Iterator, error) {
+	if it, ok := d.(Iterable); ok {
+		return it.NewIterator(ctx, opts)
+	}
+
+	keys, err := d.List(ctx, opts.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSortedIterator(keys, opts, func(key []byte) ([]byte, bool, error) {
+		return d.Get(ctx, key)
+	}), nil
+}
+
+// PrefixEnd returns the smallest key greater than all keys with the prefix.
+// Returns nil if there is no such key (empty prefix or all 0xff).
+func PrefixEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			end := make([]byte, i+1)
+			copy(end, prefix)
+			end[i]++
+			return end
+		}
+	}
+
+	return nil
+}
+
+// sortedIterator iterates over an in-memory sorted list of keys.
+type sortedIterator struct {
+	keys    [][]byte
+	getter  func(key []byte) ([]byte, bool, error)
+	reverse bool
+	limit   int
+
+	pos   int
+	count int
+	err   error
+}
+
+// NewSortedIterator builds an iterator over a list of keys.
+// The keys are filtered to the bounds of opts and sorted.
+// The getter is called to retrieve values as they are requested.
+func NewSortedIterator(
+	keys [][]byte,
+	opts IteratorOpts,
+	getter func(key []byte) ([]byte, bool, error),
+) Iterator {
+	lower, upper := opts.Bounds()
+	var ks [][]byte
+	for _, k := range keys {
+		if bytes.Compare(k, lower) < 0 {
+			continue
+		}
+		if upper != nil && bytes.Compare(k, upper) >= 0 {
+			continue
+		}
+		ks = append(ks, k)
+	}
+	sort.Slice(ks, func(i, j int) bool {
+		return bytes.Compare(ks[i], ks[j]) < 0
+	})
+
+	it := &sortedIterator{
+		keys:    ks,
+		getter:  getter,
+		reverse: opts.Reverse,
+		limit:   opts.Limit,
+	}
+	it.Seek(nil)
+	return it
+}
+
+// Seek moves the iterator to the key.
+func (i *sortedIterator) Seek(key []byte) {
+	i.count = 0
+	if !i.reverse {
+		i.pos = sort.Search(len(i.keys), func(n int) bool {
+			return bytes.Compare(i.keys[n], key) >= 0
+		})
+		return
+	}
+
+	if key == nil {
+		i.pos = len(i.keys) - 1
+		return
+	}
+
+	i.pos = sort.Search(len(i.keys), func(n int) bool {
+		return bytes.Compare(i.keys[n], key) > 0
+	}) - 1
+}
+
+// Next advances the iterator.
+func (i *sortedIterator) Next() {
+	if !i.Valid() {
+		return
+	}
+
+	i.count++
+	if i.reverse {
+		i.pos--
+	} else {
+		i.pos++
+	}
+}
+
+// Valid indicates the iterator is positioned at an entry.
+func (i *sortedIterator) Valid() bool {
+	if i.err != nil || i.pos < 0 || i.pos >= len(i.keys) {
+		return false
+	}
+
+	return i.limit == 0 || i.count < i.limit
+}
+
+// Key returns the key at the current position.
+func (i *sortedIterator) Key() []byte {
+	if !i.Valid() {
+		return nil
+	}
+
+	return i.keys[i.pos]
+}
+
+// Value returns the value at the current position.
+func (i *sortedIterator) Value() ([]byte, error) {
+	if !i.Valid() {
+		return nil, nil
+	}
+
+	val, _, err := i.getter(i.keys[i.pos])
+	if err != nil {
+		i.err = err
+		return nil, err
+	}
+
+	return copyBytes(val), nil
+}
+
+// Err returns any error encountered during iteration.
+func (i *sortedIterator) Err() error {
+	return i.err
+}
+
+// Close releases the iterator.
+func (i *sortedIterator) Close() error {
+	i.keys = nil
+	return nil
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"sort"
+)
+
+// DefaultPageSize is the page size used when enumerating all keys in pages.
+const DefaultPageSize = 1000
+
+// Pager is a database which can natively list keys in pages.
+type Pager interface {
+	// ListPage lists up to limit keys with the prefix which sort after
+	// startAfter, in ascending order. A nil startAfter starts at the first key.
+	// If limit is zero or negative, all remaining keys are returned.
+	// Returns the keys and a cursor to pass as startAfter for the next page,
+	// which is nil if there are no more keys.
+	ListPage(ctx context.Context, prefix, startAfter []byte, limit int) ([][]byte, []byte, error)
+}
+
+// ListPage lists up to limit keys with the prefix which sort after startAfter.
+// If the database does not implement Pager, a key iterator is used.
+func ListPage(ctx context.Context, d ReadOnlyDb, prefix, startAfter []byte, limit int) ([][]byte, []byte, error) {
+	if p, ok := d.(Pager); ok {
+		return p.ListPage(ctx, prefix, startAfter, limit)
+	}
+
+	opts := IteratorOpts{Prefix: prefix}
+	if startAfter != nil {
+		// the smallest key after startAfter
+		opts.Start = append(copyBytes(startAfter), 0)
+	}
+
+	it, err := NewIterator(ctx, d, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer it.Close()
+
+	var keys [][]byte
+	for ; it.Valid(); it.Next() {
+		if limit > 0 && len(keys) == limit {
+			return keys, keys[len(keys)-1], nil
+		}
+		keys = append(keys, copyBytes(it.Key()))
+	}
+
+	return keys, nil, it.Err()
+}
+
+// ForEachPage calls cb with each page of up to limit keys with the prefix, in
+// ascending order. If the database implements neither Pager nor Iterable, the
+// keys are listed once and paged in memory, instead of listed for every page.
+func ForEachPage(ctx context.Context, d ReadOnlyDb, prefix []byte, limit int, cb func(keys [][]byte) error) error {
+	return ForEachPageAfter(ctx, d, prefix, nil, limit, cb)
+}
+
+// ForEachPageAfter calls cb with each page of up to limit keys with the prefix
+// which sort after startAfter, as ForEachPage. A nil startAfter starts at the
+// first key.
+func ForEachPageAfter(ctx context.Context, d ReadOnlyDb, prefix, startAfter []byte, limit int, cb func(keys [][]byte) error) error {
+	_, isPager := d.(Pager)
+	_, isIterable := d.(Iterable)
+	if !isPager && !isIterable {
+		keys, err := d.List(ctx, prefix)
+		if err != nil {
+			return err
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return bytes.Compare(keys[i], keys[j]) < 0
+		})
+		if startAfter != nil {
+			keys = keys[sort.Search(len(keys), func(i int) bool {
+				return bytes.Compare(keys[i], startAfter) > 0
+			}):]
+		}
+
+		for len(keys) != 0 {
+			page := keys
+			if limit > 0 && len(page) > limit {
+				page = page[:limit]
+			}
+			keys = keys[len(page):]
+			if err := cb(page); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cursor := startAfter
+	for {
+		keys, next, err := ListPage(ctx, d, prefix, cursor, limit)
+		if err != nil {
+			return err
+		}
+		if len(keys) != 0 {
+			if err := cb(keys); err != nil {
+				return err
+			}
+		}
+
+		if next == nil {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// ForEachKey calls cb with each key with the prefix in ascending order.
+// Keys are listed in pages of DefaultPageSize, so the database can be modified
+// by the callback, and memory use is bounded if the database implements Pager
+// or Iterable.
+func ForEachKey(ctx context.Context, d ReadOnlyDb, prefix []byte, cb func(key []byte) error) error {
+	return ForEachPage(ctx, d, prefix, DefaultPageSize, func(keys [][]byte) error {
+		for _, key := range keys {
+			if err := cb(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
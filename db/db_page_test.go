@@ -0,0 +1,70 @@
+package db_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+// listCountingDb counts List calls, hiding the Pager and Iterable
+// implementations of the wrapped db.
+type listCountingDb struct {
+	db.Db
+	lists int
+}
+
+// List lists keys with a prefix.
+func (d *listCountingDb) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	d.lists++
+	return d.Db.List(ctx, prefix)
+}
+
+// TestForEachKeyList tests paging over a db without native paging lists once.
+func TestForEachKeyList(t *testing.T) {
+	ctx := context.Background()
+	d := &listCountingDb{Db: inmem.NewInmemDb()}
+	n := 2*db.DefaultPageSize + 1
+	for i := 0; i < n; i++ {
+		require.NoError(t, d.Set(ctx, []byte(fmt.Sprintf("/%05d", i)), nil))
+	}
+
+	var i int
+	require.NoError(t, db.ForEachKey(ctx, d, nil, func(key []byte) error {
+		require.Equal(t, fmt.Sprintf("/%05d", i), string(key))
+		i++
+		return nil
+	}))
+	require.Equal(t, n, i)
+	require.Equal(t, 1, d.lists)
+
+	require.NoError(t, db.DropPrefix(ctx, d, nil))
+	require.Equal(t, 2, d.lists)
+	keys, err := d.List(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
+
+// TestForEachPageAfter tests paging after a key with and without native paging.
+func TestForEachPageAfter(t *testing.T) {
+	ctx := context.Background()
+	inner := inmem.NewInmemDb()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, inner.Set(ctx, []byte(fmt.Sprintf("/%02d", i)), nil))
+	}
+
+	for _, d := range []db.ReadOnlyDb{inner, &listCountingDb{Db: inner}} {
+		var keys []string
+		require.NoError(t, db.ForEachPageAfter(ctx, d, nil, []byte("/04"), 3, func(page [][]byte) error {
+			require.LessOrEqual(t, len(page), 3)
+			for _, key := range page {
+				keys = append(keys, string(key))
+			}
+			return nil
+		}))
+		require.Equal(t, []string{"/05", "/06", "/07", "/08", "/09"}, keys)
+	}
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"time"
 )
 
 // Prefixer prefixes everything going in and out of a db.
@@ -28,9 +29,24 @@ func (d *Prefixer) Set(ctx context.Context, key []byte, val []byte) error {
 	return d.db.Set(ctx, d.applyPrefix(key), val)
 }
 
+// SetWithTTL sets an object in the database with a ttl.
+func (d *Prefixer) SetWithTTL(ctx context.Context, key []byte, val []byte, ttl time.Duration) error {
+	return SetWithTTL(ctx, d.db, d.applyPrefix(key), val, ttl)
+}
+
+// GetTTL returns the remaining ttl of a key.
+func (d *Prefixer) GetTTL(ctx context.Context, key []byte) (time.Duration, bool, error) {
+	return GetTTL(ctx, d.db, d.applyPrefix(key))
+}
+
 // List lists keys with a prefix.
 func (d *Prefixer) List(ctx context.Context, prefix []byte) ([][]byte, error) {
-	keyList, err := d.db.List(ctx, d.applyPrefix(prefix))
+	return d.list(ctx, d.db, prefix)
+}
+
+// list lists keys with a prefix in src, stripping the prefix.
+func (d *Prefixer) list(ctx context.Context, src ReadOnlyDb, prefix []byte) ([][]byte, error) {
+	keyList, err := src.List(ctx, d.applyPrefix(prefix))
 	if err != nil {
 		return nil, err
 	}
@@ -43,15 +59,196 @@ func (d *Prefixer) List(ctx context.Context, prefix []byte) ([][]byte, error) {
 	return keyList, nil
 }
 
+// ListPage lists a page of keys with a prefix, stripping the prefix.
+func (d *Prefixer) ListPage(ctx context.Context, prefix, startAfter []byte, limit int) ([][]byte, []byte, error) {
+	var pstartAfter []byte
+	if startAfter != nil {
+		pstartAfter = d.applyPrefix(startAfter)
+	}
+
+	keyList, cursor, err := ListPage(ctx, d.db, d.applyPrefix(prefix), pstartAfter, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// un-prefix results
+	for i, val := range keyList {
+		keyList[i] = val[len(d.prefix):]
+	}
+	if cursor != nil {
+		cursor = cursor[len(d.prefix):]
+	}
+
+	return keyList, cursor, nil
+}
+
 // Delete deletes a set of keys.
 func (d *Prefixer) Delete(ctx context.Context, keys ...[]byte) error {
-	for _, key := range keys {
-		if err := d.db.Delete(ctx, d.applyPrefix(key)); err != nil {
-			return err
-		}
+	pkeys := make([][]byte, len(keys))
+	for i, key := range keys {
+		pkeys[i] = d.applyPrefix(key)
+	}
+
+	return d.db.Delete(ctx, pkeys...)
+}
+
+// CompareAndSwap sets the key to val if the current value equals old.
+func (d *Prefixer) CompareAndSwap(ctx context.Context, key []byte, old, val []byte) (bool, error) {
+	return CompareAndSwap(ctx, d.db, d.applyPrefix(key), old, val)
+}
+
+// SetIfNotExists sets the key to val if the key does not exist.
+func (d *Prefixer) SetIfNotExists(ctx context.Context, key []byte, val []byte) (bool, error) {
+	return SetIfNotExists(ctx, d.db, d.applyPrefix(key), val)
+}
+
+// DropPrefix deletes all keys with a prefix.
+// An empty prefix deletes all keys within the Prefixer's prefix.
+func (d *Prefixer) DropPrefix(ctx context.Context, prefix []byte) error {
+	return DropPrefix(ctx, d.db, d.applyPrefix(prefix))
+}
+
+// NewTxn builds a new transaction, prefixing all keys.
+func (d *Prefixer) NewTxn(ctx context.Context, write bool) (Txn, error) {
+	txn, err := NewTxn(ctx, d.db, write)
+	if err != nil {
+		return nil, err
+	}
+
+	return &prefixTxn{Txn: txn, d: d}, nil
+}
+
+// prefixTxn prefixes everything going in and out of a transaction.
+type prefixTxn struct {
+	Txn
+	d *Prefixer
+}
+
+// Get retrieves an object from the transaction.
+func (t *prefixTxn) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	return t.Txn.Get(ctx, t.d.applyPrefix(key))
+}
+
+// Set sets an object in the transaction.
+func (t *prefixTxn) Set(ctx context.Context, key []byte, val []byte) error {
+	return t.Txn.Set(ctx, t.d.applyPrefix(key), val)
+}
+
+// Delete deletes a set of keys in the transaction.
+func (t *prefixTxn) Delete(ctx context.Context, keys ...[]byte) error {
+	pkeys := make([][]byte, len(keys))
+	for i, key := range keys {
+		pkeys[i] = t.d.applyPrefix(key)
+	}
+
+	return t.Txn.Delete(ctx, pkeys...)
+}
+
+// NewIterator builds a new iterator, stripping the prefix from keys.
+func (d *Prefixer) NewIterator(ctx context.Context, opts IteratorOpts) (Iterator, error) {
+	return d.newIterator(ctx, d.db, opts)
+}
+
+// newIterator builds a new iterator over src, stripping the prefix from keys.
+func (d *Prefixer) newIterator(ctx context.Context, src ReadOnlyDb, opts IteratorOpts) (Iterator, error) {
+	popts := opts
+	popts.Prefix = d.applyPrefix(opts.Prefix)
+	if opts.Start != nil {
+		popts.Start = d.applyPrefix(opts.Start)
+	}
+	if opts.End != nil {
+		popts.End = d.applyPrefix(opts.End)
+	}
+
+	it, err := NewIterator(ctx, src, popts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &prefixIterator{Iterator: it, d: d}, nil
+}
+
+// prefixIterator strips the prefix from keys returned by an iterator.
+type prefixIterator struct {
+	Iterator
+	d *Prefixer
+}
+
+// Seek moves the iterator to the key.
+func (i *prefixIterator) Seek(key []byte) {
+	if key == nil {
+		i.Iterator.Seek(nil)
+		return
+	}
+
+	i.Iterator.Seek(i.d.applyPrefix(key))
+}
+
+// Key returns the key at the current position.
+func (i *prefixIterator) Key() []byte {
+	key := i.Iterator.Key()
+	if len(key) < len(i.d.prefix) {
+		return key
+	}
+
+	return key[len(i.d.prefix):]
+}
+
+// Snapshot returns a read-only view of the database at this point in time.
+func (d *Prefixer) Snapshot(ctx context.Context) (ReadOnlyDb, func(), error) {
+	snap, release, err := Snapshot(ctx, d.db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &prefixSnapshot{d: d, snap: snap}, release, nil
+}
+
+// prefixSnapshot prefixes everything going in and out of a snapshot.
+type prefixSnapshot struct {
+	d    *Prefixer
+	snap ReadOnlyDb
+}
+
+// Get retrieves an object from the snapshot.
+func (s *prefixSnapshot) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	return s.snap.Get(ctx, s.d.applyPrefix(key))
+}
+
+// List lists keys with a prefix in the snapshot.
+func (s *prefixSnapshot) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	return s.d.list(ctx, s.snap, prefix)
+}
+
+// NewIterator builds a new iterator over the snapshot.
+func (s *prefixSnapshot) NewIterator(ctx context.Context, opts IteratorOpts) (Iterator, error) {
+	return s.d.newIterator(ctx, s.snap, opts)
+}
+
+// Watch watches for changes to keys with a prefix, stripping the prefix from
+// the keys in the events.
+func (d *Prefixer) Watch(ctx context.Context, prefix []byte) (<-chan Event, error) {
+	ch, err := Watch(ctx, d.db, d.applyPrefix(prefix))
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	out := make(chan Event, WatchBufferSize)
+	go func() {
+		defer close(out)
+		for ev := range ch {
+			if ev.Type != EventOverflow {
+				ev.Key = ev.Key[len(d.prefix):]
+			}
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
 }
 
 // WithPrefix adds a prefix to a database.
@@ -63,3 +260,23 @@ func (d *Prefixer) Delete(ctx context.Context, keys ...[]byte) error {
 func WithPrefix(d Db, prefix []byte) Db {
 	return &Prefixer{db: d, prefix: prefix}
 }
+
+// Close closes the underlying db.
+func (d *Prefixer) Close() error {
+	return Close(d.db)
+}
+
+// _ are type assertions
+var (
+	_ Batcher           = &Prefixer{}
+	_ Closer            = &Prefixer{}
+	_ CompareAndSwapper = &Prefixer{}
+	_ Iterable          = &Prefixer{}
+	_ Pager             = &Prefixer{}
+	_ PrefixDropper     = &Prefixer{}
+	_ Snapshotter       = &Prefixer{}
+	_ TTLGetter         = &Prefixer{}
+	_ TTLSetter         = &Prefixer{}
+	_ Watcher           = &Prefixer{}
+	_ Iterable          = &prefixSnapshot{}
+)
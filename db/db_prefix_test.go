@@ -0,0 +1,16 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/dbtest"
+	"github.com/aperturerobotics/objstore/db/inmem"
+)
+
+// TestPrefixerConformance runs the db conformance suite against a Prefixer.
+func TestPrefixerConformance(t *testing.T) {
+	dbtest.RunConformance(t, func() db.Db {
+		return db.WithPrefix(inmem.NewInmemDb(), []byte("/prefix"))
+	})
+}
@@ -0,0 +1,30 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrSnapshotNotSupported is returned when taking a snapshot of a database
+// without support for them.
+var ErrSnapshotNotSupported = errors.New("database does not support snapshots")
+
+// Snapshotter is a database which supports consistent point-in-time views.
+type Snapshotter interface {
+	// Snapshot returns a read-only view of the database at this point in time.
+	// Writes made after the snapshot is taken are not visible in it.
+	// The release function must be called when the snapshot is no longer used,
+	// after closing any iterators built from it.
+	Snapshot(ctx context.Context) (ReadOnlyDb, func(), error)
+}
+
+// Snapshot returns a read-only view of the database at this point in time.
+// Returns ErrSnapshotNotSupported if the database does not implement Snapshotter.
+func Snapshot(ctx context.Context, d Db) (ReadOnlyDb, func(), error) {
+	s, ok := d.(Snapshotter)
+	if !ok {
+		return nil, nil, ErrSnapshotNotSupported
+	}
+
+	return s.Snapshot(ctx)
+}
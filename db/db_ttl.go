@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTTLNotSupported is returned when setting a TTL on a database without
+// support for expiring keys.
+var ErrTTLNotSupported = errors.New("database does not support ttl")
+
+// TTLSetter is a database which supports keys that expire.
+type TTLSetter interface {
+	// SetWithTTL sets an object in the database which expires after the ttl.
+	// Expired keys are treated as not found.
+	// If ttl is zero or negative, the key does not expire.
+	SetWithTTL(ctx context.Context, key []byte, val []byte, ttl time.Duration) error
+}
+
+// SetWithTTL sets an object in the database with a ttl.
+// If ttl is zero or negative, the key lives forever and Set is used.
+// Returns ErrTTLNotSupported if the database does not implement TTLSetter.
+func SetWithTTL(ctx context.Context, d Db, key []byte, val []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return d.Set(ctx, key, val)
+	}
+
+	ts, ok := d.(TTLSetter)
+	if !ok {
+		return ErrTTLNotSupported
+	}
+
+	return ts.SetWithTTL(ctx, key, val, ttl)
+}
+
+// TTLGetter is a database which can report the remaining ttl of keys.
+type TTLGetter interface {
+	// GetTTL returns the remaining ttl of a key.
+	// The ttl is zero if the key does not expire.
+	// Expired keys are treated as not found.
+	GetTTL(ctx context.Context, key []byte) (time.Duration, bool, error)
+}
+
+// GetTTL returns the remaining ttl of a key, zero if the key does not expire.
+// Returns ErrTTLNotSupported if the database does not implement TTLGetter.
+func GetTTL(ctx context.Context, d Db, key []byte) (time.Duration, bool, error) {
+	tg, ok := d.(TTLGetter)
+	if !ok {
+		return 0, false, ErrTTLNotSupported
+	}
+
+	return tg.GetTTL(ctx, key)
+}
@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrTxnDiscarded is returned when using a transaction after Commit or Discard.
+var ErrTxnDiscarded = errors.New("transaction already committed or discarded")
+
+// ErrTxnReadOnly is returned when writing to a read-only transaction.
+var ErrTxnReadOnly = errors.New("transaction is read-only")
+
+// Txn is a transaction against a database.
+// Changes made in the transaction are applied all-or-nothing on Commit.
+type Txn interface {
+	// Get retrieves an object from the transaction.
+	// Uncommitted changes in the transaction are visible.
+	// Not found should return nil, false, nil
+	Get(ctx context.Context, key []byte) ([]byte, bool, error)
+	// Set sets an object in the transaction.
+	Set(ctx context.Context, key []byte, val []byte) error
+	// Delete clears a set of keys in the transaction.
+	// Not found should not return an error.
+	Delete(ctx context.Context, keys ...[]byte) error
+	// Commit atomically applies the changes in the transaction.
+	// The transaction cannot be used after Commit.
+	Commit(ctx context.Context) error
+	// Discard discards the transaction. Safe to call after Commit.
+	Discard()
+}
+
+// Batcher is a database which supports atomic transactions.
+type Batcher interface {
+	// NewTxn builds a new transaction.
+	// If write is false, the transaction is read-only.
+	NewTxn(ctx context.Context, write bool) (Txn, error)
+}
+
+// NewTxn builds a new transaction against a database.
+// If the database does not implement Batcher, writes are buffered in memory
+// and applied one at a time on Commit. An error before Commit leaves the
+// database untouched, but a crash during Commit may apply a partial set.
+func NewTxn(ctx context.Context, d Db, write bool) (Txn, error) {
+	if b, ok := d.(Batcher); ok {
+		return b.NewTxn(ctx, write)
+	}
+
+	return newBufferedTxn(d, write), nil
+}
+
+// bufferedTxn buffers writes in memory over a non-transactional database.
+type bufferedTxn struct {
+	mtx       sync.Mutex
+	db        Db
+	write     bool
+	discarded bool
+	// pending contains the pending writes, nil value indicates a delete
+	pending map[string][]byte
+}
+
+// newBufferedTxn builds a new buffered transaction.
+func newBufferedTxn(d Db, write bool) *bufferedTxn {
+	return &bufferedTxn{
+		db:      d,
+		write:   write,
+		pending: make(map[string][]byte),
+	}
+}
+
+// Get retrieves an object from the transaction.
+func (t *bufferedTxn) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	t.mtx.Lock()
+	if t.discarded {
+		t.mtx.Unlock()
+		return nil, false, ErrTxnDiscarded
+	}
+	val, ok := t.pending[string(key)]
+	t.mtx.Unlock()
+
+	if ok {
+		if val == nil {
+			return nil, false, nil
+		}
+
+		return copyBytes(val), true, nil
+	}
+
+	return t.db.Get(ctx, key)
+}
+
+// Set sets an object in the transaction.
+func (t *bufferedTxn) Set(ctx context.Context, key []byte, val []byte) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if err := t.checkWrite(); err != nil {
+		return err
+	}
+
+	v := copyBytes(val)
+	if v == nil {
+		v = []byte{}
+	}
+	t.pending[string(key)] = v
+	return nil
+}
+
+// Delete clears a set of keys in the transaction.
+func (t *bufferedTxn) Delete(ctx context.Context, keys ...[]byte) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if err := t.checkWrite(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		t.pending[string(key)] = nil
+	}
+
+	return nil
+}
+
+// Commit applies the changes in the transaction.
+func (t *bufferedTxn) Commit(ctx context.Context) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.discarded {
+		return ErrTxnDiscarded
+	}
+	t.discarded = true
+
+	// apply in key order for determinism
+	keys := make([]string, 0, len(t.pending))
+	for k := range t.pending {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var delKeys [][]byte
+	for _, k := range keys {
+		val := t.pending[k]
+		if val == nil {
+			delKeys = append(delKeys, []byte(k))
+			continue
+		}
+
+		if err := t.db.Set(ctx, []byte(k), val); err != nil {
+			return err
+		}
+	}
+
+	if len(delKeys) != 0 {
+		return t.db.Delete(ctx, delKeys...)
+	}
+
+	return nil
+}
+
+// Discard discards the transaction.
+func (t *bufferedTxn) Discard() {
+	t.mtx.Lock()
+	t.discarded = true
+	t.pending = nil
+	t.mtx.Unlock()
+}
+
+// checkWrite checks if the transaction can be written to.
+// Expects mtx to be held.
+func (t *bufferedTxn) checkWrite() error {
+	if t.discarded {
+		return ErrTxnDiscarded
+	}
+
+	if !t.write {
+		return ErrTxnReadOnly
+	}
+
+	return nil
+}
+
+// copyBytes copies a byte slice.
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+)
+
+// WatchBufferSize is the number of events buffered for each watcher.
+const WatchBufferSize = 256
+
+// ErrWatchNotSupported is returned when watching a database without support
+// for change events.
+var ErrWatchNotSupported = errors.New("database does not support watching")
+
+// EventType is the type of a change event.
+type EventType int
+
+const (
+	// EventPut indicates a key was set.
+	EventPut EventType = iota
+	// EventDelete indicates a key was deleted.
+	EventDelete
+	// EventOverflow indicates the watcher fell behind and events were dropped.
+	// It is the last event before the channel is closed, and has no key.
+	EventOverflow
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventPut:
+		return "put"
+	case EventDelete:
+		return "delete"
+	case EventOverflow:
+		return "overflow"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change to a key.
+// The slices in an event are shared between watchers and must not be modified.
+type Event struct {
+	// Type is the type of change.
+	Type EventType
+	// Key is the changed key.
+	Key []byte
+	// Value is the new value for put events, if known.
+	Value []byte
+}
+
+// Watcher is a database which emits change events.
+type Watcher interface {
+	// Watch watches for changes to keys with the prefix.
+	// The channel is closed when the context is canceled. If the receiver falls
+	// more than WatchBufferSize events behind, an EventOverflow event is sent
+	// and the channel is closed early.
+	Watch(ctx context.Context, prefix []byte) (<-chan Event, error)
+}
+
+// Watch watches for changes to keys with the prefix.
+// Returns ErrWatchNotSupported if the database does not implement Watcher.
+func Watch(ctx context.Context, d Db, prefix []byte) (<-chan Event, error) {
+	w, ok := d.(Watcher)
+	if !ok {
+		return nil, ErrWatchNotSupported
+	}
+
+	return w.Watch(ctx, prefix)
+}
+
+// WatchHub fans out change events to watchers.
+// It is used to implement Watcher. The zero value is ready to use.
+type WatchHub struct {
+	mtx      sync.Mutex
+	watchers map[*hubWatcher]struct{}
+}
+
+// NewWatchChan builds a channel for a watcher with WatchBufferSize slots for
+// events, and one reserved for the EventOverflow event.
+func NewWatchChan() chan Event {
+	return make(chan Event, WatchBufferSize+1)
+}
+
+// SendWatchEvent sends an event to a channel built with NewWatchChan without
+// blocking. If the buffer is full, EventOverflow is sent instead and false is
+// returned, after which the caller must close the channel.
+// Calls for the same channel must not be concurrent.
+func SendWatchEvent(ch chan Event, ev Event) bool {
+	if len(ch) >= WatchBufferSize {
+		ch <- Event{Type: EventOverflow}
+		return false
+	}
+
+	ch <- ev
+	return true
+}
+
+// hubWatcher is a watcher registered with a hub.
+type hubWatcher struct {
+	prefix []byte
+	ch     chan Event
+}
+
+// Watch registers a watcher for keys with the prefix.
+// The channel is closed when the context is canceled.
+func (h *WatchHub) Watch(ctx context.Context, prefix []byte) (<-chan Event, error) {
+	w := &hubWatcher{
+		prefix: copyBytes(prefix),
+		ch:     NewWatchChan(),
+	}
+
+	h.mtx.Lock()
+	if h.watchers == nil {
+		h.watchers = make(map[*hubWatcher]struct{})
+	}
+	h.watchers[w] = struct{}{}
+	h.mtx.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mtx.Lock()
+		h.remove(w)
+		h.mtx.Unlock()
+	}()
+
+	return w.ch, nil
+}
+
+// Emit sends events to the watchers with matching prefixes.
+// Emit never blocks: watchers which have fallen behind are sent an
+// EventOverflow event and closed.
+func (h *WatchHub) Emit(events ...Event) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for w := range h.watchers {
+	EventLoop:
+		for _, ev := range events {
+			if !bytes.HasPrefix(ev.Key, w.prefix) {
+				continue
+			}
+
+			if !SendWatchEvent(w.ch, ev) {
+				h.remove(w)
+				break EventLoop
+			}
+		}
+	}
+}
+
+// remove removes and closes a watcher if it is registered.
+// Expects mtx to be locked.
+func (h *WatchHub) remove(w *hubWatcher) {
+	if _, ok := h.watchers[w]; ok {
+		delete(h.watchers, w)
+		close(w.ch)
+	}
+}
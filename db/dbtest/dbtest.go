@@ -0,0 +1,634 @@
+package dbtest
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/stretchr/testify/require"
+)
+
+// Ctor builds a new empty database for a test.
+type Ctor func() db.Db
+
+// RunConformance runs the conformance suite against a db.Db implementation.
+// The constructor is called once per test and must return an empty database.
+// Capabilities are tested through the db package helpers. Transactions and
+// iterators are always tested, using the generic fallbacks if db.Batcher or
+// db.Iterable are not implemented. Tests of other optional capabilities
+// (db.CompareAndSwapper, db.Snapshotter, db.TTLSetter, db.Watcher) are skipped
+// if the helper returns the capability's not supported error.
+func RunConformance(t *testing.T, ctor Ctor) {
+	t.Run("GetNotFound", func(t *testing.T) { testGetNotFound(t, ctor()) })
+	t.Run("SetGet", func(t *testing.T) { testSetGet(t, ctor()) })
+	t.Run("EmptyValue", func(t *testing.T) { testEmptyValue(t, ctor()) })
+	t.Run("Overwrite", func(t *testing.T) { testOverwrite(t, ctor()) })
+	t.Run("ValueIsolation", func(t *testing.T) { testValueIsolation(t, ctor()) })
+	t.Run("ListPrefix", func(t *testing.T) { testListPrefix(t, ctor()) })
+	t.Run("ListEmptyPrefix", func(t *testing.T) { testListEmptyPrefix(t, ctor()) })
+	t.Run("ListPage", func(t *testing.T) { testListPage(t, ctor()) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, ctor()) })
+	t.Run("DeleteMissing", func(t *testing.T) { testDeleteMissing(t, ctor()) })
+	t.Run("ListCanceled", func(t *testing.T) { testListCanceled(t, ctor()) })
+	t.Run("Prefixer", func(t *testing.T) { testPrefixer(t, ctor()) })
+	t.Run("DropPrefix", func(t *testing.T) { testDropPrefix(t, ctor()) })
+	t.Run("TTL", func(t *testing.T) { testTTL(t, ctor()) })
+	t.Run("CompareAndSwap", func(t *testing.T) { testCompareAndSwap(t, ctor()) })
+	t.Run("Watch", func(t *testing.T) { testWatch(t, ctor()) })
+	t.Run("Snapshot", func(t *testing.T) { testSnapshot(t, ctor()) })
+	t.Run("Txn", func(t *testing.T) { testTxn(t, ctor()) })
+	t.Run("Iterator", func(t *testing.T) { testIterator(t, ctor()) })
+}
+
+// Opener opens a database stored in a directory for a test.
+type Opener func(dir string) db.Db
+
+// RunReopen runs tests of writes persisting across reopening a database.
+// Each test opens a new empty directory, and closes the database with
+// db.Close before opening the directory again.
+func RunReopen(t *testing.T, open Opener) {
+	t.Run("Reopen", func(t *testing.T) { withDir(t, open, testReopen) })
+	t.Run("DropPrefixReopen", func(t *testing.T) { withDir(t, open, testDropPrefixReopen) })
+}
+
+// withDir runs a reopen test against a new temporary directory.
+func withDir(t *testing.T, open Opener, test func(t *testing.T, open func() db.Db)) {
+	dir, err := ioutil.TempDir("", "objstore-dbtest-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	test(t, func() db.Db { return open(dir) })
+}
+
+// setKeys sets a list of keys, using the key as the value.
+func setKeys(t *testing.T, d db.Db, keys ...string) {
+	ctx := context.Background()
+	for _, k := range keys {
+		require.NoError(t, d.Set(ctx, []byte(k), []byte(k)))
+	}
+}
+
+// listKeys lists keys with a prefix as strings.
+func listKeys(t *testing.T, d db.Db, prefix string) []string {
+	var p []byte
+	if prefix != "" {
+		p = []byte(prefix)
+	}
+
+	keys, err := d.List(context.Background(), p)
+	require.NoError(t, err)
+
+	ks := make([]string, len(keys))
+	for i, k := range keys {
+		ks[i] = string(k)
+	}
+	return ks
+}
+
+// requireValue checks the value of a key.
+func requireValue(t *testing.T, d db.Db, key string, val []byte) {
+	v, found, err := d.Get(context.Background(), []byte(key))
+	require.NoError(t, err)
+	require.True(t, found, "key %q not found", key)
+	require.True(t, bytes.Equal(v, val), "key %q: expected %q got %q", key, val, v)
+}
+
+// requireNotFound checks that a key does not exist.
+func requireNotFound(t *testing.T, d db.Db, key string) {
+	v, found, err := d.Get(context.Background(), []byte(key))
+	require.NoError(t, err)
+	require.False(t, found, "key %q unexpectedly found", key)
+	require.Nil(t, v)
+}
+
+func testGetNotFound(t *testing.T, d db.Db) {
+	requireNotFound(t, d, "/missing")
+	setKeys(t, d, "/missing-not")
+	requireNotFound(t, d, "/missing")
+}
+
+func testSetGet(t *testing.T, d db.Db) {
+	setKeys(t, d, "/a", "/b")
+	requireValue(t, d, "/a", []byte("/a"))
+	requireValue(t, d, "/b", []byte("/b"))
+}
+
+func testEmptyValue(t *testing.T, d db.Db) {
+	require.NoError(t, d.Set(context.Background(), []byte("/empty"), []byte{}))
+	v, found, err := d.Get(context.Background(), []byte("/empty"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Len(t, v, 0)
+}
+
+func testOverwrite(t *testing.T, d db.Db) {
+	ctx := context.Background()
+	require.NoError(t, d.Set(ctx, []byte("/a"), []byte("one")))
+	require.NoError(t, d.Set(ctx, []byte("/a"), []byte("two")))
+	requireValue(t, d, "/a", []byte("two"))
+	require.Equal(t, []string{"/a"}, listKeys(t, d, ""))
+}
+
+func testValueIsolation(t *testing.T, d db.Db) {
+	ctx := context.Background()
+	val := []byte("value")
+	require.NoError(t, d.Set(ctx, []byte("/a"), val))
+	val[0] = 'X'
+	requireValue(t, d, "/a", []byte("value"))
+
+	got, _, err := d.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	got[0] = 'Y'
+	requireValue(t, d, "/a", []byte("value"))
+
+	keys, err := d.List(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	keys[0][0] = 'Z'
+	require.Equal(t, []string{"/a"}, listKeys(t, d, ""))
+}
+
+func testListPrefix(t *testing.T, d db.Db) {
+	setKeys(t, d, "/a", "/a/1", "/a/2", "/ab", "/b/1", "/", "a")
+	require.ElementsMatch(t, []string{"/a", "/a/1", "/a/2", "/ab"}, listKeys(t, d, "/a"))
+	require.ElementsMatch(t, []string{"/a/1", "/a/2"}, listKeys(t, d, "/a/"))
+	require.ElementsMatch(t, []string{"/b/1"}, listKeys(t, d, "/b"))
+	require.Empty(t, listKeys(t, d, "/c"))
+	require.Empty(t, listKeys(t, d, "/a/1/"))
+}
+
+func testListEmptyPrefix(t *testing.T, d db.Db) {
+	require.Empty(t, listKeys(t, d, ""))
+	setKeys(t, d, "/a", "/b/1", "c")
+	require.ElementsMatch(t, []string{"/a", "/b/1", "c"}, listKeys(t, d, ""))
+
+	keys, err := d.List(context.Background(), []byte{})
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+}
+
+func testListPage(t *testing.T, d db.Db) {
+	ctx := context.Background()
+	setKeys(t, d, "/a", "/p/1", "/p/2", "/p/3", "/p/4", "/p/5", "/q")
+
+	var pages [][]string
+	var cursor []byte
+	for {
+		keys, next, err := db.ListPage(ctx, d, []byte("/p/"), cursor, 2)
+		require.NoError(t, err)
+		page := make([]string, len(keys))
+		for i, k := range keys {
+			page[i] = string(k)
+		}
+		pages = append(pages, page)
+		if next == nil {
+			break
+		}
+		require.Equal(t, keys[len(keys)-1], next)
+		cursor = next
+	}
+	require.Equal(t, [][]string{{"/p/1", "/p/2"}, {"/p/3", "/p/4"}, {"/p/5"}}, pages)
+
+	// an exact final page has no cursor
+	keys, next, err := db.ListPage(ctx, d, []byte("/p/"), []byte("/p/3"), 2)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Nil(t, next)
+
+	// a cursor before the prefix starts at the prefix
+	keys, _, err = db.ListPage(ctx, d, []byte("/p/"), []byte("/a"), 1)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("/p/1")}, keys)
+
+	// no limit
+	keys, next, err = db.ListPage(ctx, d, nil, nil, 0)
+	require.NoError(t, err)
+	require.Len(t, keys, 7)
+	require.Nil(t, next)
+
+	// prefixes are composed
+	pd := db.WithPrefix(d, []byte("/p"))
+	keys, next, err = db.ListPage(ctx, pd, nil, []byte("/2"), 2)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("/3"), []byte("/4")}, keys)
+	require.Equal(t, []byte("/4"), next)
+
+	var all []string
+	require.NoError(t, db.ForEachKey(ctx, pd, nil, func(key []byte) error {
+		all = append(all, string(key))
+		return nil
+	}))
+	require.Equal(t, []string{"/1", "/2", "/3", "/4", "/5"}, all)
+}
+
+func testDelete(t *testing.T, d db.Db) {
+	setKeys(t, d, "/a", "/b", "/c")
+	require.NoError(t, d.Delete(context.Background(), []byte("/a"), []byte("/c")))
+	requireNotFound(t, d, "/a")
+	requireNotFound(t, d, "/c")
+	requireValue(t, d, "/b", []byte("/b"))
+	require.Equal(t, []string{"/b"}, listKeys(t, d, ""))
+}
+
+func testDeleteMissing(t *testing.T, d db.Db) {
+	ctx := context.Background()
+	require.NoError(t, d.Delete(ctx, []byte("/missing")))
+	require.NoError(t, d.Delete(ctx))
+
+	setKeys(t, d, "/a")
+	require.NoError(t, d.Delete(ctx, []byte("/missing"), []byte("/a")))
+	requireNotFound(t, d, "/a")
+}
+
+func testListCanceled(t *testing.T, d db.Db) {
+	setKeys(t, d, "/a", "/b")
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	ctxCancel()
+
+	_, err := d.List(ctx, nil)
+	require.Error(t, err)
+}
+
+func testPrefixer(t *testing.T, d db.Db) {
+	ctx := context.Background()
+	pd := db.WithPrefix(d, []byte("/ns"))
+	setKeys(t, d, "/n", "/nt")
+	setKeys(t, pd, "/a", "/b/1")
+
+	requireValue(t, d, "/ns/a", []byte("/a"))
+	requireValue(t, pd, "/b/1", []byte("/b/1"))
+	requireNotFound(t, pd, "/n")
+	require.ElementsMatch(t, []string{"/a", "/b/1"}, listKeys(t, pd, ""))
+	require.ElementsMatch(t, []string{"/b/1"}, listKeys(t, pd, "/b"))
+
+	require.NoError(t, pd.Delete(ctx, []byte("/a"), []byte("/missing")))
+	requireNotFound(t, d, "/ns/a")
+	requireValue(t, d, "/nt", []byte("/nt"))
+}
+
+func testDropPrefix(t *testing.T, d db.Db) {
+	ctx := context.Background()
+	setKeys(t, d, "/a", "/a/1", "/a/2", "/ab", "/b/1")
+	require.NoError(t, db.DropPrefix(ctx, d, []byte("/a/")))
+	require.ElementsMatch(t, []string{"/a", "/ab", "/b/1"}, listKeys(t, d, ""))
+	require.NoError(t, db.DropPrefix(ctx, d, []byte("/missing")))
+
+	// prefixes are composed
+	pd := db.WithPrefix(d, []byte("/b"))
+	setKeys(t, pd, "/1", "/2/x", "/3")
+	require.NoError(t, db.DropPrefix(ctx, pd, []byte("/2")))
+	require.ElementsMatch(t, []string{"/1", "/3"}, listKeys(t, pd, ""))
+	require.NoError(t, db.DropPrefix(ctx, pd, nil))
+	require.Empty(t, listKeys(t, pd, ""))
+	require.ElementsMatch(t, []string{"/a", "/ab"}, listKeys(t, d, ""))
+
+	require.NoError(t, db.DropPrefix(ctx, d, nil))
+	require.Empty(t, listKeys(t, d, ""))
+}
+
+func testTTL(t *testing.T, d db.Db) {
+	ctx := context.Background()
+	err := db.SetWithTTL(ctx, d, []byte("/ttl/a"), []byte("a"), time.Second)
+	if err == db.ErrTTLNotSupported {
+		t.Skip("db does not implement db.TTLSetter")
+	}
+	require.NoError(t, err)
+
+	// overwriting without a ttl clears the ttl
+	require.NoError(t, db.SetWithTTL(ctx, d, []byte("/ttl/b"), []byte("b"), time.Second))
+	require.NoError(t, d.Set(ctx, []byte("/ttl/b"), []byte("b")))
+
+	// zero and negative ttls do not expire
+	require.NoError(t, db.SetWithTTL(ctx, d, []byte("/ttl/c"), []byte("c"), 0))
+	require.NoError(t, db.SetWithTTL(ctx, d, []byte("/ttl/d"), []byte("d"), -time.Second))
+	requireValue(t, d, "/ttl/a", []byte("a"))
+	requireValue(t, d, "/ttl/d", []byte("d"))
+
+	require.Eventually(t, func() bool {
+		_, found, err := d.Get(ctx, []byte("/ttl/a"))
+		return err == nil && !found
+	}, 5*time.Second, 20*time.Millisecond)
+	require.ElementsMatch(t, []string{"/ttl/b", "/ttl/c", "/ttl/d"}, listKeys(t, d, "/ttl/"))
+	requireValue(t, d, "/ttl/b", []byte("b"))
+	requireValue(t, d, "/ttl/c", []byte("c"))
+	requireValue(t, d, "/ttl/d", []byte("d"))
+}
+
+func testCompareAndSwap(t *testing.T, d db.Db) {
+	ctx := context.Background()
+	key := []byte("/cas")
+	ok, err := db.SetIfNotExists(ctx, d, key, []byte("one"))
+	if err == db.ErrCASNotSupported {
+		t.Skip("db does not implement db.CompareAndSwapper")
+	}
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = db.SetIfNotExists(ctx, d, key, []byte("two"))
+	require.NoError(t, err)
+	require.False(t, ok)
+	requireValue(t, d, "/cas", []byte("one"))
+
+	ok, err = db.CompareAndSwap(ctx, d, key, []byte("two"), []byte("three"))
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = db.CompareAndSwap(ctx, d, key, nil, []byte("three"))
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = db.CompareAndSwap(ctx, d, key, []byte("one"), []byte{})
+	require.NoError(t, err)
+	require.True(t, ok)
+	requireValue(t, d, "/cas", []byte{})
+
+	// an empty old value does not match a missing key
+	ok, err = db.CompareAndSwap(ctx, d, []byte("/missing"), []byte{}, []byte("x"))
+	require.NoError(t, err)
+	require.False(t, ok)
+	requireNotFound(t, d, "/missing")
+
+	// concurrent increments are not lost
+	const workers, increments = 4, 25
+	var wg sync.WaitGroup
+	errCh := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < increments; {
+				cur, found, err := d.Get(ctx, []byte("/counter"))
+				if err != nil {
+					errCh <- err
+					return
+				}
+
+				var old []byte
+				next := []byte{1}
+				if found {
+					old = cur
+					next = []byte{cur[0] + 1}
+				}
+
+				ok, err := db.CompareAndSwap(ctx, d, []byte("/counter"), old, next)
+				if err != nil {
+					errCh <- err
+					return
+				}
+				if ok {
+					n++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		require.NoError(t, err)
+	}
+	requireValue(t, d, "/counter", []byte{workers * increments})
+}
+
+func testWatch(t *testing.T, d db.Db) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	pd := db.WithPrefix(d, []byte("/ns"))
+	ch, err := db.Watch(ctx, pd, []byte("/w/"))
+	if err == db.ErrWatchNotSupported {
+		t.Skip("db does not implement db.Watcher")
+	}
+	require.NoError(t, err)
+
+	// wait for the watch to start, writing a key until an event is seen
+	syncKey := []byte("/w/sync")
+	timeout := time.After(5 * time.Second)
+SyncLoop:
+	for {
+		require.NoError(t, pd.Set(ctx, syncKey, nil))
+		select {
+		case <-ch:
+			break SyncLoop
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("timed out waiting for watch to start")
+		}
+	}
+
+	setKeys(t, d, "/ns/other", "/w/a")
+	require.NoError(t, pd.Set(ctx, []byte("/w/a"), []byte("a")))
+	require.NoError(t, pd.Delete(ctx, []byte("/w/a")))
+
+	var events []db.Event
+	for len(events) < 2 {
+		select {
+		case ev, ok := <-ch:
+			require.True(t, ok, "watch channel closed")
+			if !bytes.Equal(ev.Key, syncKey) {
+				events = append(events, ev)
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for events")
+		}
+	}
+
+	require.Equal(t, db.EventPut, events[0].Type)
+	require.Equal(t, "/w/a", string(events[0].Key))
+	require.Equal(t, []byte("a"), events[0].Value)
+	require.Equal(t, db.EventDelete, events[1].Type)
+	require.Equal(t, "/w/a", string(events[1].Key))
+
+	// the channel is closed when the context is canceled
+	ctxCancel()
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for watch to close")
+		}
+	}
+}
+
+func testSnapshot(t *testing.T, d db.Db) {
+	ctx := context.Background()
+	pd := db.WithPrefix(d, []byte("/ns"))
+	setKeys(t, pd, "/a", "/b")
+
+	snap, release, err := db.Snapshot(ctx, pd)
+	if err == db.ErrSnapshotNotSupported {
+		t.Skip("db does not implement db.Snapshotter")
+	}
+	require.NoError(t, err)
+	defer release()
+
+	setKeys(t, pd, "/c")
+	require.NoError(t, pd.Set(ctx, []byte("/a"), []byte("changed")))
+	require.NoError(t, pd.Delete(ctx, []byte("/b")))
+
+	val, found, err := snap.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("/a"), val)
+	_, found, err = snap.Get(ctx, []byte("/c"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	keys, err := snap.List(ctx, nil)
+	require.NoError(t, err)
+	ks := make([]string, len(keys))
+	for i, k := range keys {
+		ks[i] = string(k)
+	}
+	require.ElementsMatch(t, []string{"/a", "/b"}, ks)
+
+	it, err := db.NewIterator(ctx, snap, db.IteratorOpts{})
+	require.NoError(t, err)
+	ks = nil
+	for ; it.Valid(); it.Next() {
+		ks = append(ks, string(it.Key()))
+	}
+	require.NoError(t, it.Err())
+	require.NoError(t, it.Close())
+	require.Equal(t, []string{"/a", "/b"}, ks)
+
+	requireValue(t, pd, "/a", []byte("changed"))
+	requireNotFound(t, pd, "/b")
+}
+
+func testTxn(t *testing.T, d db.Db) {
+	ctx := context.Background()
+	setKeys(t, d, "/a", "/b")
+
+	// discarded changes are not applied
+	txn, err := db.NewTxn(ctx, d, true)
+	require.NoError(t, err)
+	require.NoError(t, txn.Set(ctx, []byte("/c"), []byte("c")))
+	txn.Discard()
+	requireNotFound(t, d, "/c")
+
+	// committed changes are applied together
+	txn, err = db.NewTxn(ctx, d, true)
+	require.NoError(t, err)
+	require.NoError(t, txn.Set(ctx, []byte("/c"), []byte("c")))
+	require.NoError(t, txn.Delete(ctx, []byte("/a"), []byte("/missing")))
+
+	// values are copied on Set, the caller may reuse the buffer
+	buf := []byte("d")
+	require.NoError(t, txn.Set(ctx, []byte("/d"), buf))
+	buf[0] = 'X'
+
+	v, found, err := txn.Get(ctx, []byte("/c"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("c"), v)
+	_, found, err = txn.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, txn.Commit(ctx))
+	txn.Discard()
+	requireNotFound(t, d, "/a")
+	requireValue(t, d, "/b", []byte("/b"))
+	requireValue(t, d, "/c", []byte("c"))
+	requireValue(t, d, "/d", []byte("d"))
+
+	// read-only transactions cannot be written to
+	txn, err = db.NewTxn(ctx, d, false)
+	require.NoError(t, err)
+	defer txn.Discard()
+	v, found, err = txn.Get(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("/b"), v)
+	require.Error(t, txn.Set(ctx, []byte("/e"), []byte("e")))
+}
+
+func testIterator(t *testing.T, d db.Db) {
+	ctx := context.Background()
+	setKeys(t, d, "/a", "/b/1", "/b/2", "/b/3", "/c")
+
+	iterKeys := func(opts db.IteratorOpts) []string {
+		it, err := db.NewIterator(ctx, d, opts)
+		require.NoError(t, err)
+		defer it.Close()
+
+		var ks []string
+		for ; it.Valid(); it.Next() {
+			val, err := it.Value()
+			require.NoError(t, err)
+			require.Equal(t, string(it.Key()), string(val))
+			ks = append(ks, string(it.Key()))
+		}
+		require.NoError(t, it.Err())
+		return ks
+	}
+
+	require.Equal(t, []string{"/a", "/b/1", "/b/2", "/b/3", "/c"}, iterKeys(db.IteratorOpts{}))
+	require.Equal(t, []string{"/b/1", "/b/2", "/b/3"}, iterKeys(db.IteratorOpts{Prefix: []byte("/b/")}))
+	require.Equal(t, []string{"/b/3", "/b/2"}, iterKeys(db.IteratorOpts{
+		Prefix:  []byte("/b/"),
+		Reverse: true,
+		Limit:   2,
+	}))
+	require.Equal(t, []string{"/b/2", "/b/3"}, iterKeys(db.IteratorOpts{
+		Start: []byte("/b/2"),
+		End:   []byte("/c"),
+	}))
+	require.Equal(t, []string{"/b/3", "/b/2"}, iterKeys(db.IteratorOpts{
+		Start:   []byte("/b/2"),
+		End:     []byte("/c"),
+		Reverse: true,
+	}))
+
+	// seek within the bounds
+	it, err := db.NewIterator(ctx, d, db.IteratorOpts{Prefix: []byte("/b/")})
+	require.NoError(t, err)
+	defer it.Close()
+	it.Seek([]byte("/b/2"))
+	require.True(t, it.Valid())
+	require.Equal(t, "/b/2", string(it.Key()))
+	it.Seek([]byte("/a"))
+	require.True(t, it.Valid())
+	require.Equal(t, "/b/1", string(it.Key()))
+	it.Seek([]byte("/c"))
+	require.False(t, it.Valid())
+}
+
+func testReopen(t *testing.T, open func() db.Db) {
+	d := open()
+	setKeys(t, d, "/a", "/b")
+	require.NoError(t, db.Close(d))
+
+	d = open()
+	defer db.Close(d)
+	require.ElementsMatch(t, []string{"/a", "/b"}, listKeys(t, d, ""))
+	requireValue(t, d, "/a", []byte("/a"))
+}
+
+func testDropPrefixReopen(t *testing.T, open func() db.Db) {
+	ctx := context.Background()
+	d := open()
+	setKeys(t, d, "/a/1", "/a/2", "/b/1")
+	require.NoError(t, db.DropPrefix(ctx, d, []byte("/a/")))
+	require.NoError(t, db.Close(d))
+
+	d = open()
+	require.ElementsMatch(t, []string{"/b/1"}, listKeys(t, d, ""))
+	require.NoError(t, db.DropPrefix(ctx, d, nil))
+	require.Empty(t, listKeys(t, d, ""))
+	require.NoError(t, db.Close(d))
+
+	d = open()
+	require.Empty(t, listKeys(t, d, ""))
+	setKeys(t, d, "/c")
+	require.NoError(t, db.Close(d))
+
+	d = open()
+	defer db.Close(d)
+	require.ElementsMatch(t, []string{"/c"}, listKeys(t, d, ""))
+}
@@ -0,0 +1,523 @@
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+)
+
+// formatVersion is the version byte prepended to sealed values.
+const formatVersion byte = 1
+
+// fingerprintLen is the length of the key fingerprint in sealed values.
+const fingerprintLen = 4
+
+// keySeparator separates namespace segments in keys.
+const keySeparator = '/'
+
+// hashedSegmentLen is the number of HMAC bytes kept per key segment.
+const hashedSegmentLen = 16
+
+// ErrUnknownKey is returned when a value was sealed with an unknown key.
+var ErrUnknownKey = errors.New("value sealed with unknown encryption key")
+
+// ErrInvalidValue is returned when a stored value cannot be decoded.
+var ErrInvalidValue = errors.New("invalid encrypted value")
+
+// ErrTTLUnknown is returned by Rotate when the ttl of values cannot be kept.
+var ErrTTLUnknown = errors.New("underlying database cannot report key ttls")
+
+// Config configures an encrypted database.
+type Config struct {
+	// Key is the current encryption key.
+	// Must be 16, 24, or 32 bytes, selecting AES-128, AES-192, or AES-256 GCM.
+	Key []byte
+	// OldKeys are previous encryption keys, used to open values which have
+	// not been rotated to Key yet.
+	OldKeys [][]byte
+	// KeyHashKey enables hashing keys with HMAC-SHA256 under this key.
+	// Each '/' separated segment of the key is hashed independently, so a
+	// namespace prefix ending with '/' can still be listed efficiently.
+	// The original key is stored sealed alongside the value.
+	KeyHashKey []byte
+}
+
+// RotateOpts are options for Rotate.
+type RotateOpts struct {
+	// SkipInvalid skips values which cannot be opened, such as values not
+	// written by the EncryptedDb, instead of failing.
+	SkipInvalid bool
+	// DropTTL re-writes values without a ttl if the underlying db can expire
+	// keys but cannot report their remaining ttl, instead of failing.
+	DropTTL bool
+}
+
+// sealer is an AEAD with its key fingerprint.
+type sealer struct {
+	aead        cipher.AEAD
+	fingerprint []byte
+}
+
+// newSealer builds a sealer for a key.
+func newSealer(key []byte) (*sealer, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.Sum256(key)
+	return &sealer{aead: aead, fingerprint: h[:fingerprintLen]}, nil
+}
+
+// EncryptedDb seals values going in and out of a db with an AEAD.
+type EncryptedDb struct {
+	db db.Db
+	// rotateMtx is held for writing during Rotate, and reading during writes.
+	rotateMtx sync.RWMutex
+	// mtx guards current and sealers
+	mtx     sync.RWMutex
+	current *sealer
+	sealers []*sealer
+	hashKey []byte
+}
+
+// NewEncryptedDb builds a new encrypted database wrapping d.
+func NewEncryptedDb(d db.Db, conf Config) (*EncryptedDb, error) {
+	e := &EncryptedDb{db: d}
+	if len(conf.KeyHashKey) != 0 {
+		e.hashKey = make([]byte, len(conf.KeyHashKey))
+		copy(e.hashKey, conf.KeyHashKey)
+	}
+
+	current, err := newSealer(conf.Key)
+	if err != nil {
+		return nil, err
+	}
+	e.current = current
+	e.sealers = append(e.sealers, current)
+
+	for _, key := range conf.OldKeys {
+		s, err := newSealer(key)
+		if err != nil {
+			return nil, err
+		}
+		e.sealers = append(e.sealers, s)
+	}
+
+	return e, nil
+}
+
+// storageKey returns the key used in the underlying database.
+func (e *EncryptedDb) storageKey(key []byte) []byte {
+	if e.hashKey == nil {
+		return key
+	}
+
+	var out []byte
+	segs := bytes.Split(key, []byte{keySeparator})
+	for i, seg := range segs {
+		if i != 0 {
+			out = append(out, keySeparator)
+		}
+		out = append(out, e.hashSegment(seg)...)
+	}
+	return out
+}
+
+// storagePrefix returns the underlying prefix to list for a prefix.
+// Only complete segments can be hashed, the remainder is filtered after.
+func (e *EncryptedDb) storagePrefix(prefix []byte) []byte {
+	if e.hashKey == nil {
+		return prefix
+	}
+
+	idx := bytes.LastIndexByte(prefix, keySeparator)
+	if idx < 0 {
+		return nil
+	}
+
+	return append(e.storageKey(prefix[:idx]), keySeparator)
+}
+
+// hashSegment hashes a single key segment.
+func (e *EncryptedDb) hashSegment(seg []byte) []byte {
+	if len(seg) == 0 {
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, e.hashKey)
+	_, _ = mac.Write(seg)
+	sum := mac.Sum(nil)
+	return []byte(hex.EncodeToString(sum[:hashedSegmentLen]))
+}
+
+// seal seals a value, returning the data to store.
+// The storage key is used as additional data, binding the value to the key.
+func (e *EncryptedDb) seal(s *sealer, storageKey, key, val []byte) ([]byte, error) {
+	var plaintext []byte
+	if e.hashKey != nil {
+		plaintext = make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(key)+len(val))
+		n := binary.PutUvarint(plaintext, uint64(len(key)))
+		plaintext = append(plaintext[:n], key...)
+		plaintext = append(plaintext, val...)
+	} else {
+		plaintext = val
+	}
+
+	nonceSize := s.aead.NonceSize()
+	hdrLen := 1 + fingerprintLen + nonceSize
+	out := make([]byte, hdrLen, hdrLen+len(plaintext)+s.aead.Overhead())
+	out[0] = formatVersion
+	copy(out[1:], s.fingerprint)
+	nonce := out[1+fingerprintLen : hdrLen]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return s.aead.Seal(out, nonce, plaintext, storageKey), nil
+}
+
+// open opens a sealed value, returning the original key, value and sealer.
+func (e *EncryptedDb) open(storageKey, data []byte) ([]byte, []byte, *sealer, error) {
+	if len(data) < 1+fingerprintLen || data[0] != formatVersion {
+		return nil, nil, nil, ErrInvalidValue
+	}
+
+	fingerprint := data[1 : 1+fingerprintLen]
+	e.mtx.RLock()
+	var s *sealer
+	for _, si := range e.sealers {
+		if bytes.Equal(si.fingerprint, fingerprint) {
+			s = si
+			break
+		}
+	}
+	e.mtx.RUnlock()
+	if s == nil {
+		return nil, nil, nil, ErrUnknownKey
+	}
+
+	nonceSize := s.aead.NonceSize()
+	hdrLen := 1 + fingerprintLen + nonceSize
+	if len(data) < hdrLen {
+		return nil, nil, nil, ErrInvalidValue
+	}
+
+	plaintext, err := s.aead.Open(nil, data[1+fingerprintLen:hdrLen], data[hdrLen:], storageKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if e.hashKey == nil {
+		return storageKey, plaintext, s, nil
+	}
+
+	keyLen, n := binary.Uvarint(plaintext)
+	if n <= 0 || uint64(len(plaintext)-n) < keyLen {
+		return nil, nil, nil, ErrInvalidValue
+	}
+	key := plaintext[n : n+int(keyLen)]
+	return key, plaintext[n+int(keyLen):], s, nil
+}
+
+// currentSealer returns the current sealer.
+func (e *EncryptedDb) currentSealer() *sealer {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	return e.current
+}
+
+// Get retrieves an object from the database.
+func (e *EncryptedDb) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	skey := e.storageKey(key)
+	data, found, err := e.db.Get(ctx, skey)
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	_, val, _, err := e.open(skey, data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return val, true, nil
+}
+
+// Set sets an object in the database.
+func (e *EncryptedDb) Set(ctx context.Context, key []byte, val []byte) error {
+	return e.SetWithTTL(ctx, key, val, 0)
+}
+
+// SetWithTTL sets an object in the database with a ttl.
+func (e *EncryptedDb) SetWithTTL(ctx context.Context, key []byte, val []byte, ttl time.Duration) error {
+	e.rotateMtx.RLock()
+	defer e.rotateMtx.RUnlock()
+
+	skey := e.storageKey(key)
+	data, err := e.seal(e.currentSealer(), skey, key, val)
+	if err != nil {
+		return err
+	}
+
+	return db.SetWithTTL(ctx, e.db, skey, data, ttl)
+}
+
+// GetTTL returns the remaining ttl of a key.
+func (e *EncryptedDb) GetTTL(ctx context.Context, key []byte) (time.Duration, bool, error) {
+	return db.GetTTL(ctx, e.db, e.storageKey(key))
+}
+
+// List returns a list of keys with the specified prefix.
+// If keys are hashed, the original keys are recovered from the values.
+func (e *EncryptedDb) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	if e.hashKey == nil {
+		return e.db.List(ctx, prefix)
+	}
+
+	skeys, err := e.db.List(ctx, e.storagePrefix(prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys [][]byte
+	for _, skey := range skeys {
+		data, found, err := e.db.Get(ctx, skey)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+
+		key, _, _, err := e.open(skey, data)
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// Delete clears a set of keys from the db.
+func (e *EncryptedDb) Delete(ctx context.Context, keys ...[]byte) error {
+	e.rotateMtx.RLock()
+	defer e.rotateMtx.RUnlock()
+
+	skeys := make([][]byte, len(keys))
+	for i, key := range keys {
+		skeys[i] = e.storageKey(key)
+	}
+
+	return e.db.Delete(ctx, skeys...)
+}
+
+// NewTxn builds a new transaction, sealing and opening values in a
+// transaction against the underlying db.
+func (e *EncryptedDb) NewTxn(ctx context.Context, write bool) (db.Txn, error) {
+	txn, err := db.NewTxn(ctx, e.db, write)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedTxn{
+		Txn:  txn,
+		e:    e,
+		s:    e.currentSealer(),
+		vals: make(map[string]*txnValue),
+	}, nil
+}
+
+// txnValue is a value set in a transaction.
+type txnValue struct {
+	key, val []byte
+}
+
+// encryptedTxn seals values going in and out of a transaction.
+type encryptedTxn struct {
+	db.Txn
+	e *EncryptedDb
+	// s is the sealer used for values set in the transaction.
+	s *sealer
+	// vals are the values set in the transaction by storage key, kept to seal
+	// them again if the key is rotated before Commit.
+	vals map[string]*txnValue
+}
+
+// Get retrieves an object from the transaction.
+func (t *encryptedTxn) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	skey := t.e.storageKey(key)
+	data, found, err := t.Txn.Get(ctx, skey)
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	_, val, _, err := t.e.open(skey, data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return val, true, nil
+}
+
+// Set sets an object in the transaction.
+func (t *encryptedTxn) Set(ctx context.Context, key []byte, val []byte) error {
+	skey := t.e.storageKey(key)
+	data, err := t.e.seal(t.s, skey, key, val)
+	if err != nil {
+		return err
+	}
+
+	if err := t.Txn.Set(ctx, skey, data); err != nil {
+		return err
+	}
+
+	t.vals[string(skey)] = &txnValue{key: copyBytes(key), val: copyBytes(val)}
+	return nil
+}
+
+// Delete deletes a set of keys in the transaction.
+func (t *encryptedTxn) Delete(ctx context.Context, keys ...[]byte) error {
+	skeys := make([][]byte, len(keys))
+	for i, key := range keys {
+		skeys[i] = t.e.storageKey(key)
+		delete(t.vals, string(skeys[i]))
+	}
+
+	return t.Txn.Delete(ctx, skeys...)
+}
+
+// Commit commits the transaction.
+// Values are sealed again if the key was rotated since they were set.
+func (t *encryptedTxn) Commit(ctx context.Context) error {
+	t.e.rotateMtx.RLock()
+	defer t.e.rotateMtx.RUnlock()
+
+	if s := t.e.currentSealer(); s != t.s {
+		for skey, v := range t.vals {
+			data, err := t.e.seal(s, []byte(skey), v.key, v.val)
+			if err != nil {
+				return err
+			}
+			if err := t.Txn.Set(ctx, []byte(skey), data); err != nil {
+				return err
+			}
+		}
+		t.s = s
+	}
+
+	return t.Txn.Commit(ctx)
+}
+
+// copyBytes copies a byte slice.
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// Rotate changes the encryption key to newKey, re-encrypting all values in
+// place. The previous keys are kept to open values until rotation completes.
+// Writes through the EncryptedDb are blocked while rotating.
+// If rotation fails part way, it can be safely called again.
+// Values keep their remaining ttl. If the underlying db can expire keys but
+// does not implement db.TTLGetter, ErrTTLUnknown is returned unless
+// opts.DropTTL is set.
+func (e *EncryptedDb) Rotate(ctx context.Context, newKey []byte, opts RotateOpts) error {
+	ns, err := newSealer(newKey)
+	if err != nil {
+		return err
+	}
+
+	e.rotateMtx.Lock()
+	defer e.rotateMtx.Unlock()
+
+	e.mtx.Lock()
+	sealers := []*sealer{ns}
+	for _, s := range e.sealers {
+		if !bytes.Equal(s.fingerprint, ns.fingerprint) {
+			sealers = append(sealers, s)
+		}
+	}
+	e.current, e.sealers = ns, sealers
+	e.mtx.Unlock()
+
+	_, canExpire := e.db.(db.TTLSetter)
+	err = db.ForEachKey(ctx, e.db, nil, func(skey []byte) error {
+		data, found, err := e.db.Get(ctx, skey)
+		if err != nil || !found {
+			return err
+		}
+
+		key, val, s, err := e.open(skey, data)
+		if err != nil {
+			if opts.SkipInvalid {
+				return nil
+			}
+			return err
+		}
+		if s == ns {
+			return nil
+		}
+
+		ttl, found, err := db.GetTTL(ctx, e.db, skey)
+		if err == db.ErrTTLNotSupported {
+			if canExpire && !opts.DropTTL {
+				return ErrTTLUnknown
+			}
+			ttl, found, err = 0, true, nil
+		}
+		if err != nil || !found {
+			return err
+		}
+
+		ndata, err := e.seal(ns, skey, key, val)
+		if err != nil {
+			return err
+		}
+		return db.SetWithTTL(ctx, e.db, skey, ndata, ttl)
+	})
+	if err != nil {
+		return err
+	}
+
+	// drop the old keys
+	e.mtx.Lock()
+	e.sealers = []*sealer{ns}
+	e.mtx.Unlock()
+	return nil
+}
+
+// Close closes the underlying db.
+func (e *EncryptedDb) Close() error {
+	return db.Close(e.db)
+}
+
+// _ are type assertions
+var (
+	_ db.Db        = &EncryptedDb{}
+	_ db.Batcher   = &EncryptedDb{}
+	_ db.Closer    = &EncryptedDb{}
+	_ db.TTLGetter = &EncryptedDb{}
+	_ db.TTLSetter = &EncryptedDb{}
+)
@@ -0,0 +1,200 @@
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/cached"
+	"github.com/aperturerobotics/objstore/db/compressed"
+	"github.com/aperturerobotics/objstore/db/dbtest"
+	"github.com/aperturerobotics/objstore/db/inmem"
+	"github.com/aperturerobotics/objstore/db/metrics"
+	"github.com/aperturerobotics/objstore/db/watched"
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = bytes.Repeat([]byte{1}, 32)
+
+// TestConformance runs the db conformance suite.
+func TestConformance(t *testing.T) {
+	t.Run("Plain", func(t *testing.T) {
+		dbtest.RunConformance(t, func() db.Db {
+			d, err := NewEncryptedDb(inmem.NewInmemDb(), Config{Key: testKey})
+			require.NoError(t, err)
+			return d
+		})
+	})
+	t.Run("HashedKeys", func(t *testing.T) {
+		dbtest.RunConformance(t, func() db.Db {
+			d, err := NewEncryptedDb(inmem.NewInmemDb(), Config{
+				Key:        testKey,
+				KeyHashKey: []byte("hash key"),
+			})
+			require.NoError(t, err)
+			return d
+		})
+	})
+}
+
+// TestRotate tests rotating the encryption key.
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	under := inmem.NewInmemDb()
+	d, err := NewEncryptedDb(under, Config{Key: testKey, KeyHashKey: []byte("hash key")})
+	require.NoError(t, err)
+
+	require.NoError(t, d.Set(ctx, []byte("/ns/a"), []byte("hello")))
+	require.NoError(t, d.Set(ctx, []byte("/ns/b"), []byte("world")))
+
+	// values and keys are not stored in the clear
+	ukeys, err := under.List(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, ukeys, 2)
+	for _, ukey := range ukeys {
+		require.False(t, bytes.Contains(ukey, []byte("ns")))
+		uval, _, err := under.Get(ctx, ukey)
+		require.NoError(t, err)
+		require.False(t, bytes.Contains(uval, []byte("hello")))
+	}
+
+	newKey := bytes.Repeat([]byte{2}, 32)
+	require.NoError(t, d.Rotate(ctx, newKey, RotateOpts{}))
+
+	val, found, err := d.Get(ctx, []byte("/ns/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("hello"), val)
+
+	// the old key can no longer open the values
+	old, err := NewEncryptedDb(under, Config{Key: testKey, KeyHashKey: []byte("hash key")})
+	require.NoError(t, err)
+	_, _, err = old.Get(ctx, []byte("/ns/b"))
+	require.Equal(t, ErrUnknownKey, err)
+
+	// the new key can
+	nd, err := NewEncryptedDb(under, Config{Key: newKey, KeyHashKey: []byte("hash key")})
+	require.NoError(t, err)
+	keys, err := nd.List(ctx, []byte("/ns/"))
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+}
+
+// ttlSetterDb is a db which can expire keys but not report their ttl.
+type ttlSetterDb struct {
+	db.Db
+}
+
+// SetWithTTL sets an object in the database with a ttl.
+func (d *ttlSetterDb) SetWithTTL(ctx context.Context, key []byte, val []byte, ttl time.Duration) error {
+	return db.SetWithTTL(ctx, d.Db, key, val, ttl)
+}
+
+// TestRotateTTL tests rotating keys with a ttl and foreign values.
+func TestRotateTTL(t *testing.T) {
+	ctx := context.Background()
+	under := inmem.NewInmemDb()
+	d, err := NewEncryptedDb(under, Config{Key: testKey})
+	require.NoError(t, err)
+
+	require.NoError(t, d.SetWithTTL(ctx, []byte("/a"), []byte("a"), time.Hour))
+	require.NoError(t, d.Set(ctx, []byte("/b"), []byte("b")))
+	require.NoError(t, under.Set(ctx, []byte("/foreign"), []byte("plain")))
+
+	newKey := bytes.Repeat([]byte{2}, 32)
+	require.Equal(t, ErrInvalidValue, d.Rotate(ctx, newKey, RotateOpts{}))
+	require.NoError(t, d.Rotate(ctx, newKey, RotateOpts{SkipInvalid: true}))
+
+	ttl, found, err := d.GetTTL(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, ttl > 59*time.Minute && ttl <= time.Hour)
+	ttl, found, err = d.GetTTL(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Zero(t, ttl)
+	val, _, err := under.Get(ctx, []byte("/foreign"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("plain"), val)
+
+	// the ttl cannot be kept if the db cannot report it
+	sd, err := NewEncryptedDb(&ttlSetterDb{Db: inmem.NewInmemDb()}, Config{Key: testKey})
+	require.NoError(t, err)
+	require.NoError(t, sd.Set(ctx, []byte("/a"), []byte("a")))
+	require.Equal(t, ErrTTLUnknown, sd.Rotate(ctx, newKey, RotateOpts{}))
+	require.NoError(t, sd.Rotate(ctx, newKey, RotateOpts{DropTTL: true}))
+	val, _, err = sd.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("a"), val)
+}
+
+// TestRotateWrapped tests rotating keys with a ttl through other wrappers.
+func TestRotateWrapped(t *testing.T) {
+	wrappers := map[string]func(d db.Db) db.Db{
+		"Cached": func(d db.Db) db.Db {
+			return cached.NewCachedDb(d, cached.Config{})
+		},
+		"Compressed": func(d db.Db) db.Db {
+			c, err := compressed.NewCompressedDb(d, compressed.Config{})
+			require.NoError(t, err)
+			return c
+		},
+		"Metrics": func(d db.Db) db.Db {
+			return metrics.NewMetricsDb(d, metrics.Config{})
+		},
+		"Watched": func(d db.Db) db.Db {
+			return watched.NewWatchedDb(d)
+		},
+	}
+	for name, wrap := range wrappers {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			d, err := NewEncryptedDb(wrap(inmem.NewInmemDb()), Config{Key: testKey})
+			require.NoError(t, err)
+
+			require.NoError(t, d.SetWithTTL(ctx, []byte("/a"), []byte("a"), time.Hour))
+			require.NoError(t, d.Rotate(ctx, bytes.Repeat([]byte{2}, 32), RotateOpts{}))
+
+			ttl, found, err := d.GetTTL(ctx, []byte("/a"))
+			require.NoError(t, err)
+			require.True(t, found)
+			require.True(t, ttl > 59*time.Minute && ttl <= time.Hour)
+		})
+	}
+}
+
+// TestTxn tests values in transactions are sealed in the underlying txn.
+func TestTxn(t *testing.T) {
+	ctx := context.Background()
+	under := inmem.NewInmemDb()
+	d, err := NewEncryptedDb(under, Config{Key: testKey, KeyHashKey: []byte("hash key")})
+	require.NoError(t, err)
+
+	txn, err := d.NewTxn(ctx, true)
+	require.NoError(t, err)
+	defer txn.Discard()
+	require.NoError(t, txn.Set(ctx, []byte("/ns/a"), []byte("hello")))
+	val, found, err := txn.Get(ctx, []byte("/ns/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("hello"), val)
+
+	// the value is not written until commit
+	ukeys, err := under.List(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, ukeys)
+
+	// values are sealed again if the key is rotated before commit
+	newKey := bytes.Repeat([]byte{2}, 32)
+	require.NoError(t, d.Rotate(ctx, newKey, RotateOpts{}))
+	require.NoError(t, txn.Commit(ctx))
+
+	nd, err := NewEncryptedDb(under, Config{Key: newKey, KeyHashKey: []byte("hash key")})
+	require.NoError(t, err)
+	val, found, err = nd.Get(ctx, []byte("/ns/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("hello"), val)
+}
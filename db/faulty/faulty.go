@@ -0,0 +1,351 @@
+package faulty
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+)
+
+// ErrInjected is the default error returned by failed operations.
+var ErrInjected = errors.New("injected fault")
+
+// Op is a set of database operations.
+type Op int
+
+const (
+	// OpGet matches Get calls, including in transactions.
+	OpGet Op = 1 << iota
+	// OpSet matches Set calls.
+	OpSet
+	// OpList matches List calls, the key is the listed prefix.
+	OpList
+	// OpDelete matches Delete calls.
+	OpDelete
+	// OpCommit matches transaction commits, the keys are the written keys.
+	OpCommit
+
+	// OpRead matches all reads.
+	OpRead = OpGet | OpList
+	// OpWrite matches all writes.
+	OpWrite = OpSet | OpDelete | OpCommit
+	// OpAll matches all operations.
+	OpAll = OpRead | OpWrite
+)
+
+// Action is a fault to inject.
+type Action int
+
+const (
+	// Fail fails the operation with the rule error.
+	Fail Action = iota
+	// Delay delays the operation before running it.
+	Delay
+	// Drop skips the operation and reports success.
+	// Dropped reads return not found or no keys.
+	Drop
+)
+
+// Rule selects operations to inject a fault into.
+type Rule struct {
+	// Ops are the operations to match. If zero, all operations match.
+	Ops Op
+	// Key matches operations on keys matching the pattern.
+	// Operations on multiple keys match if any key matches.
+	// If nil, all keys match.
+	Key *regexp.Regexp
+	// Probability is the probability a matching call is faulted.
+	// If zero, matching calls are always faulted.
+	Probability float64
+	// Skip is the number of matching calls to let through before faulting.
+	Skip int
+	// Times is the maximum number of faults to inject. If zero, it is unlimited.
+	Times int
+
+	// Action is the fault to inject.
+	Action Action
+	// Err is the error returned by the Fail action. If nil, ErrInjected is used.
+	Err error
+	// Delay is the delay of the Delay action.
+	Delay time.Duration
+}
+
+// ruleState is a rule with its counters.
+type ruleState struct {
+	Rule
+	calls    int
+	injected int
+}
+
+// Config configures a fault injecting database.
+type Config struct {
+	// Rules are the rules to apply, in order. The first rule which faults a call
+	// is applied.
+	Rules []Rule
+	// Seed seeds the random source used for probabilities.
+	Seed int64
+}
+
+// FaultyDb injects faults into operations on a db.
+type FaultyDb struct {
+	db db.Db
+
+	mtx   sync.Mutex
+	rules []*ruleState
+	rand  *rand.Rand
+	// crashAfter is the number of writes before the crash, -1 if not set.
+	crashAfter int
+	crashed    bool
+}
+
+// NewFaultyDb builds a new fault injecting database wrapping d.
+func NewFaultyDb(d db.Db, conf Config) *FaultyDb {
+	f := &FaultyDb{
+		db:         d,
+		rand:       rand.New(rand.NewSource(conf.Seed)),
+		crashAfter: -1,
+	}
+	for _, r := range conf.Rules {
+		f.AddRule(r)
+	}
+	return f
+}
+
+// AddRule adds a rule after the existing rules.
+func (f *FaultyDb) AddRule(r Rule) {
+	f.mtx.Lock()
+	f.rules = append(f.rules, &ruleState{Rule: r})
+	f.mtx.Unlock()
+}
+
+// ClearRules removes all rules.
+func (f *FaultyDb) ClearRules() {
+	f.mtx.Lock()
+	f.rules = nil
+	f.mtx.Unlock()
+}
+
+// CrashAfter simulates a crash after n more writes: all writes after the nth
+// are discarded while reporting success. Reads still reach the db, so the
+// crash is only observable by reopening the db without the FaultyDb.
+// Each Set, Delete and transaction commit is one write.
+func (f *FaultyDb) CrashAfter(n int) {
+	f.mtx.Lock()
+	f.crashAfter = n
+	f.crashed = false
+	f.mtx.Unlock()
+}
+
+// Crashed checks if writes are being discarded due to a crash.
+func (f *FaultyDb) Crashed() bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	return f.crashed
+}
+
+// match finds the rule to apply to an operation on keys, if any.
+func (f *FaultyDb) match(op Op, keys ...[]byte) *Rule {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	for _, r := range f.rules {
+		if r.Ops != 0 && r.Ops&op == 0 {
+			continue
+		}
+		if r.Key != nil && !matchAny(r.Key, keys) {
+			continue
+		}
+
+		r.calls++
+		if r.calls <= r.Skip || (r.Times != 0 && r.injected >= r.Times) {
+			continue
+		}
+		if r.Probability != 0 && f.rand.Float64() >= r.Probability {
+			continue
+		}
+
+		r.injected++
+		rule := r.Rule
+		return &rule
+	}
+
+	return nil
+}
+
+// matchAny checks if any of the keys match the pattern.
+func matchAny(re *regexp.Regexp, keys [][]byte) bool {
+	for _, key := range keys {
+		if re.Match(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// inject applies the faults for an operation.
+// Returns true if the operation should be dropped.
+func (f *FaultyDb) inject(ctx context.Context, op Op, keys ...[]byte) (bool, error) {
+	r := f.match(op, keys...)
+	if r == nil {
+		return false, nil
+	}
+
+	switch r.Action {
+	case Delay:
+		t := time.NewTimer(r.Delay)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-t.C:
+			return false, nil
+		}
+	case Drop:
+		return true, nil
+	default:
+		if r.Err != nil {
+			return false, r.Err
+		}
+		return false, ErrInjected
+	}
+}
+
+// discardWrite counts a write, checking if it should be discarded due to a crash.
+func (f *FaultyDb) discardWrite() bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if f.crashAfter < 0 {
+		return false
+	}
+	if f.crashAfter == 0 {
+		f.crashed = true
+		return true
+	}
+
+	f.crashAfter--
+	return false
+}
+
+// Get retrieves an object from the database.
+func (f *FaultyDb) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	drop, err := f.inject(ctx, OpGet, key)
+	if err != nil || drop {
+		return nil, false, err
+	}
+
+	return f.db.Get(ctx, key)
+}
+
+// Set sets an object in the database.
+func (f *FaultyDb) Set(ctx context.Context, key []byte, val []byte) error {
+	drop, err := f.inject(ctx, OpSet, key)
+	if err != nil || drop || f.discardWrite() {
+		return err
+	}
+
+	return f.db.Set(ctx, key, val)
+}
+
+// List returns a list of keys with the specified prefix.
+func (f *FaultyDb) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	drop, err := f.inject(ctx, OpList, prefix)
+	if err != nil || drop {
+		return nil, err
+	}
+
+	return f.db.List(ctx, prefix)
+}
+
+// Delete clears a set of keys from the db.
+func (f *FaultyDb) Delete(ctx context.Context, keys ...[]byte) error {
+	drop, err := f.inject(ctx, OpDelete, keys...)
+	if err != nil || drop || f.discardWrite() {
+		return err
+	}
+
+	return f.db.Delete(ctx, keys...)
+}
+
+// NewTxn builds a new transaction.
+// Writes to the transaction are faulted when it is committed.
+func (f *FaultyDb) NewTxn(ctx context.Context, write bool) (db.Txn, error) {
+	txn, err := db.NewTxn(ctx, f.db, write)
+	if err != nil {
+		return nil, err
+	}
+
+	return &faultyTxn{Txn: txn, f: f}, nil
+}
+
+// faultyTxn injects faults into a transaction.
+type faultyTxn struct {
+	db.Txn
+	f *FaultyDb
+	// keys are the written keys
+	keys [][]byte
+}
+
+// Get retrieves an object from the transaction.
+func (t *faultyTxn) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	drop, err := t.f.inject(ctx, OpGet, key)
+	if err != nil || drop {
+		return nil, false, err
+	}
+
+	return t.Txn.Get(ctx, key)
+}
+
+// Set sets an object in the transaction.
+func (t *faultyTxn) Set(ctx context.Context, key []byte, val []byte) error {
+	t.keys = append(t.keys, copyBytes(key))
+	return t.Txn.Set(ctx, key, val)
+}
+
+// Delete deletes a set of keys in the transaction.
+func (t *faultyTxn) Delete(ctx context.Context, keys ...[]byte) error {
+	for _, key := range keys {
+		t.keys = append(t.keys, copyBytes(key))
+	}
+	return t.Txn.Delete(ctx, keys...)
+}
+
+// Commit commits the transaction as a single write.
+// Committing a transaction without writes is not counted as a write.
+// Dropped and discarded commits discard the underlying transaction.
+func (t *faultyTxn) Commit(ctx context.Context) error {
+	drop, err := t.f.inject(ctx, OpCommit, t.keys...)
+	if err != nil {
+		return err
+	}
+	if drop || (len(t.keys) != 0 && t.f.discardWrite()) {
+		t.Txn.Discard()
+		return nil
+	}
+
+	return t.Txn.Commit(ctx)
+}
+
+// copyBytes copies a byte slice.
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// Close closes the underlying db.
+func (f *FaultyDb) Close() error {
+	return db.Close(f.db)
+}
+
+// _ are type assertions
+var (
+	_ db.Db      = &FaultyDb{}
+	_ db.Batcher = &FaultyDb{}
+	_ db.Closer  = &FaultyDb{}
+)
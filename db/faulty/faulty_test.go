@@ -0,0 +1,159 @@
+package faulty
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/dbtest"
+	"github.com/aperturerobotics/objstore/db/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance runs the db conformance suite.
+func TestConformance(t *testing.T) {
+	dbtest.RunConformance(t, func() db.Db {
+		return NewFaultyDb(inmem.NewInmemDb(), Config{})
+	})
+}
+
+// TestRules tests failing, delaying and dropping operations.
+func TestRules(t *testing.T) {
+	ctx := context.Background()
+	inner := inmem.NewInmemDb()
+	f := NewFaultyDb(inner, Config{
+		Rules: []Rule{
+			{Ops: OpSet, Key: regexp.MustCompile("^/fail/"), Skip: 1, Times: 1},
+			{Ops: OpDelete, Action: Drop},
+			{Ops: OpGet, Key: regexp.MustCompile("^/slow"), Action: Delay, Delay: 10 * time.Millisecond},
+		},
+	})
+
+	// the first call is skipped, the second fails, then the rule is exhausted
+	require.NoError(t, f.Set(ctx, []byte("/fail/a"), []byte("a")))
+	require.Equal(t, ErrInjected, f.Set(ctx, []byte("/fail/a"), []byte("b")))
+	require.NoError(t, f.Set(ctx, []byte("/fail/a"), []byte("c")))
+
+	require.NoError(t, f.Delete(ctx, []byte("/fail/a")))
+	_, found, err := inner.Get(ctx, []byte("/fail/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+
+	start := time.Now()
+	_, _, err = f.Get(ctx, []byte("/slow"))
+	require.NoError(t, err)
+	require.True(t, time.Since(start) >= 10*time.Millisecond)
+
+	// commits are faulted with the written keys
+	f.ClearRules()
+	f.AddRule(Rule{Ops: OpCommit, Key: regexp.MustCompile("^/txn/")})
+	txn, err := f.NewTxn(ctx, true)
+	require.NoError(t, err)
+	require.NoError(t, txn.Set(ctx, []byte("/txn/a"), []byte("a")))
+	require.Equal(t, ErrInjected, txn.Commit(ctx))
+	txn.Discard()
+	_, found, err = inner.Get(ctx, []byte("/txn/a"))
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+// TestProbability tests faults are injected with a probability.
+func TestProbability(t *testing.T) {
+	ctx := context.Background()
+	f := NewFaultyDb(inmem.NewInmemDb(), Config{
+		Rules: []Rule{{Probability: 0.5}},
+		Seed:  1,
+	})
+
+	var failed int
+	for i := 0; i < 1000; i++ {
+		if _, _, err := f.Get(ctx, []byte("/a")); err != nil {
+			failed++
+		}
+	}
+	require.InDelta(t, 500, failed, 100)
+}
+
+// TestCrash tests writes after a crash are discarded.
+func TestCrash(t *testing.T) {
+	ctx := context.Background()
+	inner := inmem.NewInmemDb()
+	f := NewFaultyDb(inner, Config{})
+	f.CrashAfter(2)
+
+	require.NoError(t, f.Set(ctx, []byte("/a"), []byte("a")))
+	txn, err := f.NewTxn(ctx, true)
+	require.NoError(t, err)
+	require.NoError(t, txn.Set(ctx, []byte("/b"), []byte("b")))
+	require.NoError(t, txn.Commit(ctx))
+	txn.Discard()
+	require.False(t, f.Crashed())
+
+	require.NoError(t, f.Set(ctx, []byte("/c"), []byte("c")))
+	require.NoError(t, f.Delete(ctx, []byte("/a")))
+	require.True(t, f.Crashed())
+
+	keys, err := inner.List(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	_, found, err := inner.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+}
+
+// discardDb records discarded transactions.
+type discardDb struct {
+	db.Db
+	discarded int
+}
+
+// NewTxn builds a new transaction recording Discard calls.
+func (d *discardDb) NewTxn(ctx context.Context, write bool) (db.Txn, error) {
+	txn, err := db.NewTxn(ctx, d.Db, write)
+	if err != nil {
+		return nil, err
+	}
+	return &discardTxn{Txn: txn, d: d}, nil
+}
+
+// discardTxn records Discard calls.
+type discardTxn struct {
+	db.Txn
+	d *discardDb
+}
+
+// Discard discards the transaction.
+func (t *discardTxn) Discard() {
+	t.d.discarded++
+	t.Txn.Discard()
+}
+
+// TestCommitDiscard tests dropped and discarded commits discard the transaction.
+func TestCommitDiscard(t *testing.T) {
+	ctx := context.Background()
+	inner := &discardDb{Db: inmem.NewInmemDb()}
+	f := NewFaultyDb(inner, Config{
+		Rules: []Rule{{Ops: OpCommit, Action: Drop, Times: 1}},
+	})
+
+	commit := func(key string) {
+		txn, err := f.NewTxn(ctx, true)
+		require.NoError(t, err)
+		require.NoError(t, txn.Set(ctx, []byte(key), []byte(key)))
+		require.NoError(t, txn.Commit(ctx))
+	}
+
+	commit("/a")
+	require.Equal(t, 1, inner.discarded)
+
+	f.CrashAfter(0)
+	commit("/b")
+	require.Equal(t, 2, inner.discarded)
+	require.True(t, f.Crashed())
+
+	keys, err := inner.List(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
@@ -0,0 +1,70 @@
+package fsdb
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+// upperhex is the hex alphabet used for escapes.
+const upperhex = "0123456789ABCDEF"
+
+// shouldEscape checks if a byte at an index in a key must be escaped.
+// A leading dot is escaped so that names never collide with "." or ".." or
+// temporary files. Uppercase letters are escaped so that keys which differ
+// only in case do not collide on case-insensitive filesystems.
+func shouldEscape(c byte, i int) bool {
+	switch {
+	case 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+		return false
+	case c == '-' || c == '_' || c == '~':
+		return false
+	case c == '.':
+		return i == 0
+	default:
+		return true
+	}
+}
+
+// escapeKey escapes a key into a file name.
+// Bytes other than lowercase letters, digits, and unreserved characters are
+// encoded as %XX. Uppercase letters only appear in escape sequences.
+func escapeKey(key []byte) string {
+	buf := make([]byte, 0, len(key))
+	for i, c := range key {
+		if shouldEscape(c, i) {
+			buf = append(buf, '%', upperhex[c>>4], upperhex[c&15])
+		} else {
+			buf = append(buf, c)
+		}
+	}
+
+	return string(buf)
+}
+
+// unescapeKey decodes a file name into a key.
+func unescapeKey(name string) ([]byte, error) {
+	key := make([]byte, 0, len(name))
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c != '%' {
+			key = append(key, c)
+			continue
+		}
+
+		if i+2 >= len(name) {
+			return nil, errors.New("truncated escape sequence")
+		}
+
+		b, err := hex.DecodeString(name[i+1 : i+3])
+		if err != nil {
+			return nil, err
+		}
+		key = append(key, b[0])
+		i += 2
+	}
+
+	if len(key) == 0 {
+		return nil, errors.New("empty key")
+	}
+	return key, nil
+}
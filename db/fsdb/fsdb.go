@@ -0,0 +1,202 @@
+package fsdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/aperturerobotics/objstore/db"
+)
+
+// ErrEmptyKey is returned when using an empty key.
+var ErrEmptyKey = errors.New("key cannot be empty")
+
+// tmpPrefix is the file name prefix of in-progress writes.
+// Encoded keys never begin with a dot, so these never collide.
+const tmpPrefix = ".tmp-"
+
+// FsDB implements Db with a plain directory of files.
+//
+// Each key is stored in a file named with the escaped key, in a shard
+// directory named with the first byte of the SHA-256 of the key in hex:
+//
+//	<root>/<shard>/<escaped key>
+//
+// Writes are atomic and durable: the value is written to a temporary file,
+// synced, and renamed, then the directory is synced.
+// Keys are limited by the maximum file name length of the filesystem.
+// Escaped keys are case-insensitively unique, so case-insensitive filesystems
+// are supported.
+type FsDB struct {
+	root string
+}
+
+// NewFsDB builds a new filesystem database, creating the root if necessary.
+func NewFsDB(root string) (db.Db, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FsDB{root: root}, nil
+}
+
+// shardDir returns the shard directory for a key.
+func (d *FsDB) shardDir(key []byte) string {
+	h := sha256.Sum256(key)
+	return filepath.Join(d.root, hex.EncodeToString(h[:1]))
+}
+
+// keyPath returns the file path for a key.
+func (d *FsDB) keyPath(key []byte) string {
+	return filepath.Join(d.shardDir(key), escapeKey(key))
+}
+
+// Get retrieves an object from the database.
+// Not found should return nil, nil
+func (d *FsDB) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	if len(key) == 0 {
+		return nil, false, ErrEmptyKey
+	}
+
+	val, err := ioutil.ReadFile(d.keyPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return val, true, nil
+}
+
+// Set sets an object in the database.
+func (d *FsDB) Set(ctx context.Context, key []byte, val []byte) error {
+	if len(key) == 0 {
+		return ErrEmptyKey
+	}
+
+	dir := d.shardDir(key)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := syncDir(d.root); err != nil {
+			return err
+		}
+	}
+
+	f, err := ioutil.TempFile(dir, tmpPrefix)
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	_, err = f.Write(val)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, filepath.Join(dir, escapeKey(key)))
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	// persist the rename
+	return syncDir(dir)
+}
+
+// syncDir syncs a directory, persisting changes to the entries in it.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		// directories cannot be opened for syncing on windows
+		return nil
+	}
+
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	err = f.Sync()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// List lists keys in the database by walking the shard directories.
+func (d *FsDB) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	shards, err := ioutil.ReadDir(d.root)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys [][]byte
+	for _, shard := range shards {
+		if !shard.IsDir() || strings.HasPrefix(shard.Name(), ".") {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(d.root, shard.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			name := file.Name()
+			if file.IsDir() || strings.HasPrefix(name, ".") {
+				continue
+			}
+
+			key, err := unescapeKey(name)
+			if err != nil || escapeKey(key) != name {
+				// not a key written by FsDB
+				continue
+			}
+
+			if bytes.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	return keys, nil
+}
+
+// Delete deletes a set of keys from the db.
+func (d *FsDB) Delete(ctx context.Context, keys ...[]byte) error {
+	for _, key := range keys {
+		if len(key) == 0 {
+			continue
+		}
+
+		if err := os.Remove(d.keyPath(key)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// _ is a type assertion
+var _ db.Db = &FsDB{}
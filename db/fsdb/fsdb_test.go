@@ -0,0 +1,79 @@
+package fsdb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/dbtest"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance runs the db conformance suite.
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "objstore-fsdb-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	dbtest.RunConformance(t, func() db.Db {
+		root, err := ioutil.TempDir(dir, "fsdb-")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		d, err := NewFsDB(root)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return d
+	})
+}
+
+// TestReopen runs the db reopen suite.
+func TestReopen(t *testing.T) {
+	dbtest.RunReopen(t, func(dir string) db.Db {
+		d, err := NewFsDB(dir)
+		require.NoError(t, err)
+		return d
+	})
+}
+
+// TestEscapeKey tests escaped keys are unique ignoring case.
+func TestEscapeKey(t *testing.T) {
+	keys := []string{"/a", "/A", "/Ab", "/aB", "%41", ".a", "a.b", "/\xff"}
+	seen := make(map[string]string)
+	for _, k := range keys {
+		name := escapeKey([]byte(k))
+		key, err := unescapeKey(name)
+		require.NoError(t, err)
+		require.Equal(t, k, string(key))
+
+		folded := strings.ToLower(name)
+		require.NotContains(t, seen, folded, "%q collides with %q", k, seen[folded])
+		seen[folded] = k
+	}
+	require.Equal(t, "%41b", escapeKey([]byte("Ab")))
+
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "objstore-fsdb-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d, err := NewFsDB(dir)
+	require.NoError(t, err)
+	require.NoError(t, d.Set(ctx, []byte("/Key"), []byte("upper")))
+	require.NoError(t, d.Set(ctx, []byte("/key"), []byte("lower")))
+	val, found, err := d.Get(ctx, []byte("/Key"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("upper"), val)
+
+	listed, err := d.List(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("/Key"), []byte("/key")}, listed)
+}
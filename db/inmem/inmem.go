@@ -3,6 +3,9 @@ package inmem
 import (
 	"bytes"
 	"context"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/Workiva/go-datastructures/trie/ctrie"
 	"github.com/aperturerobotics/objstore/db"
@@ -10,7 +13,31 @@ import (
 
 // InmemDb is a in-memory database.
 type InmemDb struct {
-	ct *ctrie.Ctrie
+	// mtx guards multi-key writes, allowing atomic transaction commits.
+	mtx sync.RWMutex
+	ct  *ctrie.Ctrie
+	// expiryQueue is the queue of keys with a ttl, guarded by mtx.
+	expiryQueue expiryQueue
+	// watch emits change events, events are emitted while holding mtx.
+	watch db.WatchHub
+	// sortedMtx guards building sorted while mtx is read locked.
+	sortedMtx sync.Mutex
+	// sorted is a sorted view of the keys in ct used by ListPage, or nil.
+	// Writers holding mtx drop it when inserting a new key. Keys removed since
+	// it was built are skipped when reading it.
+	sorted [][]byte
+}
+
+// inmemEntry is a value stored in the ctrie.
+type inmemEntry struct {
+	val []byte
+	// expires is the expiry time, zero if the entry does not expire.
+	expires time.Time
+}
+
+// expired checks if the entry has expired.
+func (e *inmemEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
 }
 
 // NewInmemDb returns a in-memory database.
@@ -20,31 +47,147 @@ func NewInmemDb() db.Db {
 	}
 }
 
+// lookup looks up a non-expired value in a ctrie.
+func lookup(ct *ctrie.Ctrie, key []byte, now time.Time) ([]byte, bool) {
+	obj, ok := ct.Lookup(key)
+	if !ok {
+		return nil, false
+	}
+
+	ent := obj.(*inmemEntry)
+	if ent.expired(now) {
+		return nil, false
+	}
+
+	return ent.val, true
+}
+
 // Get retrieves an object from the database.
 // Not found should return nil, nil
 func (m *InmemDb) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
-	obj, ok := m.ct.Lookup(key)
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	val, ok := lookup(m.ct, key, time.Now())
 	if !ok {
 		return nil, false, nil
 	}
 
-	return obj.([]byte), true, nil
+	return copyBytes(val), true, nil
 }
 
 // Set sets an object in the database.
 func (m *InmemDb) Set(ctx context.Context, key []byte, val []byte) error {
-	m.ct.Insert(key, val)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	key, val = copyBytes(key), copyBytes(val)
+	m.insert(key, &inmemEntry{val: val})
+	m.watch.Emit(db.Event{Type: db.EventPut, Key: key, Value: val})
 	return nil
 }
 
+// insert inserts an entry into the ctrie, dropping the sorted view of the
+// keys if the key is new. Expired keys are removed first, so they do not
+// accumulate while the database is written to.
+// Expects mtx to be locked.
+func (m *InmemDb) insert(key []byte, ent *inmemEntry) {
+	if len(m.expiryQueue) != 0 {
+		m.sweep(time.Now())
+	}
+	if m.sorted != nil {
+		if _, ok := m.ct.Lookup(key); !ok {
+			m.sorted = nil
+		}
+	}
+	m.ct.Insert(key, ent)
+}
+
 // List returns a list of keys with the specified prefix.
 func (m *InmemDb) List(ctx context.Context, prefix []byte) ([][]byte, error) {
-	entryCh := m.ct.Iterator(ctx.Done())
+	m.mtx.RLock()
+	ct := m.ct.ReadOnlySnapshot()
+	m.mtx.RUnlock()
+
+	return listKeys(ctx, ct, prefix)
+}
+
+// ListPage lists a page of keys with a prefix in ascending order.
+// The ctrie is unordered, so pages are read from a sorted view of the keys,
+// which is built on first use and kept until a new key is inserted.
+func (m *InmemDb) ListPage(ctx context.Context, prefix, startAfter []byte, limit int) ([][]byte, []byte, error) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	sorted, err := m.sortedKeys(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	i := sort.Search(len(sorted), func(n int) bool {
+		return bytes.Compare(sorted[n], prefix) >= 0
+	})
+	if startAfter != nil {
+		j := sort.Search(len(sorted), func(n int) bool {
+			return bytes.Compare(sorted[n], startAfter) > 0
+		})
+		if j > i {
+			i = j
+		}
+	}
+
+	now := time.Now()
+	var keys [][]byte
+	for ; i < len(sorted) && bytes.HasPrefix(sorted[i], prefix); i++ {
+		if _, ok := lookup(m.ct, sorted[i], now); !ok {
+			continue
+		}
+		if limit > 0 && len(keys) == limit {
+			return keys, keys[limit-1], nil
+		}
+		keys = append(keys, copyBytes(sorted[i]))
+	}
+
+	return keys, nil, nil
+}
+
+// sortedKeys returns the sorted view of the keys, building it if necessary.
+// Expects mtx to be read locked.
+func (m *InmemDb) sortedKeys(ctx context.Context) ([][]byte, error) {
+	m.sortedMtx.Lock()
+	defer m.sortedMtx.Unlock()
+
+	if m.sorted != nil {
+		return m.sorted, nil
+	}
+
+	ks, err := listKeys(ctx, m.ct.ReadOnlySnapshot(), nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ks, func(i, j int) bool {
+		return bytes.Compare(ks[i], ks[j]) < 0
+	})
+	if ks == nil {
+		ks = [][]byte{}
+	}
+
+	m.sorted = ks
+	return ks, nil
+}
+
+// listKeys lists non-expired keys with a prefix in a ctrie.
+func listKeys(ctx context.Context, ct *ctrie.Ctrie, prefix []byte) ([][]byte, error) {
+	now := time.Now()
+	entryCh := ct.Iterator(ctx.Done())
 	var ks [][]byte
 	for entry := range entryCh {
 		key := entry.Key
+		if entry.Value.(*inmemEntry).expired(now) {
+			continue
+		}
 		if len(prefix) == 0 || bytes.HasPrefix(key, prefix) {
-			ks = append(ks, key)
+			ks = append(ks, copyBytes(key))
 		}
 	}
 
@@ -58,9 +201,141 @@ func (m *InmemDb) List(ctx context.Context, prefix []byte) ([][]byte, error) {
 
 // Delete deletes a set of keys from the database.
 func (m *InmemDb) Delete(ctx context.Context, keys ...[]byte) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	for _, key := range keys {
-		m.ct.Remove(key)
+		if _, ok := m.ct.Remove(key); ok {
+			m.watch.Emit(db.Event{Type: db.EventDelete, Key: copyBytes(key)})
+		}
 	}
 
 	return nil
 }
+
+// CompareAndSwap sets the key to val if the current value equals old.
+// Writes are serialized, so the compare and set are atomic.
+func (m *InmemDb) CompareAndSwap(ctx context.Context, key []byte, old, val []byte) (bool, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	cur, found := lookup(m.ct, key, time.Now())
+	if old == nil {
+		if found {
+			return false, nil
+		}
+	} else if !found || !bytes.Equal(cur, old) {
+		return false, nil
+	}
+
+	key, val = copyBytes(key), copyBytes(val)
+	m.insert(key, &inmemEntry{val: val})
+	m.watch.Emit(db.Event{Type: db.EventPut, Key: key, Value: val})
+	return true, nil
+}
+
+// SetIfNotExists sets the key to val if the key does not exist.
+func (m *InmemDb) SetIfNotExists(ctx context.Context, key []byte, val []byte) (bool, error) {
+	return m.CompareAndSwap(ctx, key, nil, val)
+}
+
+// DropPrefix deletes all keys with a prefix.
+// The keys are found with a sweep over a snapshot of the ctrie, and removed
+// while holding the write lock.
+func (m *InmemDb) DropPrefix(ctx context.Context, prefix []byte) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.sorted = nil
+	for entry := range m.ct.ReadOnlySnapshot().Iterator(ctx.Done()) {
+		if bytes.HasPrefix(entry.Key, prefix) {
+			m.ct.Remove(entry.Key)
+			m.watch.Emit(db.Event{Type: db.EventDelete, Key: entry.Key})
+		}
+	}
+
+	return ctx.Err()
+}
+
+// Watch watches for changes to keys with a prefix.
+// Expired keys emit delete events when they are removed by a write or Sweep.
+func (m *InmemDb) Watch(ctx context.Context, prefix []byte) (<-chan db.Event, error) {
+	return m.watch.Watch(ctx, prefix)
+}
+
+// NewIterator builds a new iterator over a snapshot of the database.
+// The ctrie is unordered, so the keys in range are collected and sorted.
+func (m *InmemDb) NewIterator(ctx context.Context, opts db.IteratorOpts) (db.Iterator, error) {
+	m.mtx.RLock()
+	ct := m.ct.ReadOnlySnapshot()
+	m.mtx.RUnlock()
+
+	return newIterator(ctx, ct, opts)
+}
+
+// newIterator builds a new iterator over a ctrie snapshot.
+func newIterator(ctx context.Context, ct *ctrie.Ctrie, opts db.IteratorOpts) (db.Iterator, error) {
+	ks, err := listKeys(ctx, ct, opts.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.NewSortedIterator(ks, opts, func(key []byte) ([]byte, bool, error) {
+		val, ok := lookup(ct, key, time.Now())
+		return val, ok, nil
+	}), nil
+}
+
+// Snapshot returns a read-only view of the database at this point in time.
+// The snapshot is a ctrie read-only snapshot, which is taken in constant time.
+// The release function is a no-op.
+func (m *InmemDb) Snapshot(ctx context.Context) (db.ReadOnlyDb, func(), error) {
+	m.mtx.RLock()
+	ct := m.ct.ReadOnlySnapshot()
+	m.mtx.RUnlock()
+
+	return &inmemSnapshot{ct: ct}, func() {}, nil
+}
+
+// inmemSnapshot is a read-only snapshot of the database.
+type inmemSnapshot struct {
+	ct *ctrie.Ctrie
+}
+
+// Get retrieves an object from the snapshot.
+func (s *inmemSnapshot) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	val, ok := lookup(s.ct, key, time.Now())
+	if !ok {
+		return nil, false, nil
+	}
+
+	return copyBytes(val), true, nil
+}
+
+// List returns a list of keys with the specified prefix in the snapshot.
+func (s *inmemSnapshot) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	return listKeys(ctx, s.ct, prefix)
+}
+
+// NewIterator builds a new iterator over the snapshot.
+func (s *inmemSnapshot) NewIterator(ctx context.Context, opts db.IteratorOpts) (db.Iterator, error) {
+	return newIterator(ctx, s.ct, opts)
+}
+
+// copyBytes copies a byte slice, returning an empty slice for nil.
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// _ are type assertions
+var (
+	_ db.CompareAndSwapper = &InmemDb{}
+	_ db.Iterable          = &InmemDb{}
+	_ db.Pager             = &InmemDb{}
+	_ db.PrefixDropper     = &InmemDb{}
+	_ db.Snapshotter       = &InmemDb{}
+	_ db.Watcher           = &InmemDb{}
+	_ db.Iterable          = &inmemSnapshot{}
+)
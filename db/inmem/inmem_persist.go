@@ -0,0 +1,141 @@
+package inmem
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Workiva/go-datastructures/trie/ctrie"
+	"github.com/aperturerobotics/objstore/db"
+)
+
+// SaveTo writes a snapshot of the database to w in the db.Dump format.
+// The snapshot is a ctrie read-only snapshot, so writes are not blocked while
+// saving. Keys set with a TTL are saved with their expiry time.
+func (m *InmemDb) SaveTo(w io.Writer) error {
+	return db.Dump(context.Background(), m, nil, w)
+}
+
+// LoadFrom replaces the contents of the database with a snapshot written by
+// SaveTo. The snapshot is fully read before the contents are replaced, so the
+// database is unchanged if it is invalid. Keys which expired since the
+// snapshot was saved are not loaded. Watchers are not notified.
+func (m *InmemDb) LoadFrom(r io.Reader) error {
+	l := &InmemDb{ct: ctrie.New(nil)}
+	if err := db.Restore(context.Background(), l, r); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	m.ct = l.ct
+	m.sorted = nil
+	m.expiryQueue = l.expiryQueue
+	m.mtx.Unlock()
+	return nil
+}
+
+// SaveToFile saves a snapshot of the database to a file.
+// The snapshot is written to a temporary file in the same directory which then
+// atomically replaces the file.
+func (m *InmemDb) SaveToFile(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+
+	err = m.SaveTo(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+// LoadFromFile replaces the contents of the database with a snapshot file.
+func (m *InmemDb) LoadFromFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return m.LoadFrom(f)
+}
+
+// RunAutosave saves the database to a file at the interval until the context
+// is canceled. Returns the first error saving the database.
+func (m *InmemDb) RunAutosave(ctx context.Context, path string, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			if err := m.SaveToFile(path); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// FileDb is an in-memory database persisted to a snapshot file.
+type FileDb struct {
+	*InmemDb
+
+	path string
+	// autosaveCancel stops the autosave, if running.
+	autosaveCancel context.CancelFunc
+	// autosaveDone is closed when the autosave exits.
+	autosaveDone chan struct{}
+}
+
+// OpenFileDb opens an in-memory database persisted to a snapshot file.
+// The file is loaded if it exists. If saveInterval is positive the database is
+// saved at the interval until a save fails, and it is always saved on Close.
+func OpenFileDb(path string, saveInterval time.Duration) (*FileDb, error) {
+	m := &InmemDb{ct: ctrie.New(nil)}
+	if err := m.LoadFromFile(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	f := &FileDb{InmemDb: m, path: path}
+	if saveInterval > 0 {
+		var ctx context.Context
+		ctx, f.autosaveCancel = context.WithCancel(context.Background())
+		f.autosaveDone = make(chan struct{})
+		go func() {
+			defer close(f.autosaveDone)
+			_ = m.RunAutosave(ctx, path, saveInterval)
+		}()
+	}
+
+	return f, nil
+}
+
+// Close stops the autosave and saves the database to the file.
+// If an autosave failed, the autosave stopped, and the error is returned by
+// the final save if it persists.
+func (f *FileDb) Close() error {
+	if f.autosaveCancel != nil {
+		f.autosaveCancel()
+		<-f.autosaveDone
+	}
+
+	return f.SaveToFile(f.path)
+}
+
+// _ is a type assertion
+var _ db.ClosableDb = &FileDb{}
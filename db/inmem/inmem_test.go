@@ -0,0 +1,222 @@
+package inmem
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/dbtest"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance runs the db conformance suite.
+func TestConformance(t *testing.T) {
+	dbtest.RunConformance(t, func() db.Db {
+		return NewInmemDb()
+	})
+}
+
+// TestListPageView tests the sorted view of keys is kept across pages.
+func TestListPageView(t *testing.T) {
+	ctx := context.Background()
+	m := NewInmemDb().(*InmemDb)
+	for _, k := range []string{"/c", "/a", "/d", "/b"} {
+		require.NoError(t, m.Set(ctx, []byte(k), []byte(k)))
+	}
+
+	keys, next, err := m.ListPage(ctx, nil, nil, 2)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("/a"), []byte("/b")}, keys)
+	require.Len(t, m.sorted, 4)
+
+	// overwrites and deletes keep the view
+	require.NoError(t, m.Set(ctx, []byte("/d"), []byte("d")))
+	require.NoError(t, m.Delete(ctx, []byte("/c")))
+	require.Len(t, m.sorted, 4)
+	keys, next, err = m.ListPage(ctx, nil, next, 2)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("/d")}, keys)
+	require.Nil(t, next)
+
+	// new keys drop the view
+	require.NoError(t, m.Set(ctx, []byte("/e"), nil))
+	require.Nil(t, m.sorted)
+	keys, _, err = m.ListPage(ctx, nil, []byte("/b"), 0)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("/d"), []byte("/e")}, keys)
+}
+
+// TestSweeper tests removing expired keys with the sweeper.
+func TestSweeper(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	m := NewInmemDb().(*InmemDb)
+	require.NoError(t, m.SetWithTTL(ctx, []byte("/a"), []byte("a"), time.Minute))
+	require.NoError(t, m.SetWithTTL(ctx, []byte("/b"), []byte("b"), time.Hour))
+	require.NoError(t, m.SetWithTTL(ctx, []byte("/c"), []byte("c"), time.Minute))
+	require.NoError(t, m.Set(ctx, []byte("/c"), []byte("c")))
+
+	// the overwritten key is skipped
+	require.Equal(t, 1, m.Sweep(time.Now().Add(2*time.Minute)))
+	require.Equal(t, 0, m.Sweep(time.Now().Add(2*time.Minute)))
+	_, found := m.ct.Lookup([]byte("/a"))
+	require.False(t, found)
+	_, found = m.ct.Lookup([]byte("/c"))
+	require.True(t, found)
+
+	require.NoError(t, m.SetWithTTL(ctx, []byte("/d"), []byte("d"), time.Millisecond))
+	go m.RunSweeper(ctx, time.Millisecond)
+	require.Eventually(t, func() bool {
+		m.mtx.RLock()
+		defer m.mtx.RUnlock()
+
+		_, found := m.ct.Lookup([]byte("/d"))
+		return !found && len(m.expiryQueue) == 1
+	}, 5*time.Second, time.Millisecond)
+	requireKeys(t, m, "/b", "/c")
+}
+
+// TestSweepOnWrite tests writes remove expired keys.
+func TestSweepOnWrite(t *testing.T) {
+	ctx := context.Background()
+	m := NewInmemDb().(*InmemDb)
+	require.NoError(t, m.SetWithTTL(ctx, []byte("/a"), []byte("a"), time.Millisecond))
+	require.NoError(t, m.SetWithTTL(ctx, []byte("/b"), []byte("b"), time.Hour))
+	time.Sleep(5 * time.Millisecond)
+
+	require.NoError(t, m.Set(ctx, []byte("/c"), []byte("c")))
+	_, found := m.ct.Lookup([]byte("/a"))
+	require.False(t, found)
+	require.Len(t, m.expiryQueue, 1)
+	requireKeys(t, m, "/b", "/c")
+}
+
+// requireKeys checks the keys in the database.
+func requireKeys(t *testing.T, m *InmemDb, keys ...string) {
+	ks, err := m.List(context.Background(), nil)
+	require.NoError(t, err)
+
+	var found []string
+	for _, k := range ks {
+		found = append(found, string(k))
+	}
+	require.ElementsMatch(t, keys, found)
+}
+
+// TestSaveLoad tests saving and loading a snapshot.
+func TestSaveLoad(t *testing.T) {
+	ctx := context.Background()
+	m := NewInmemDb().(*InmemDb)
+	require.NoError(t, m.Set(ctx, []byte("/a"), []byte("a")))
+	require.NoError(t, m.Set(ctx, []byte("/b"), nil))
+
+	var buf bytes.Buffer
+	require.NoError(t, m.SaveTo(&buf))
+	data := buf.Bytes()
+
+	l := NewInmemDb().(*InmemDb)
+	require.NoError(t, l.Set(ctx, []byte("/c"), []byte("c")))
+	require.Error(t, l.LoadFrom(bytes.NewReader(data[:len(data)-1])))
+	require.NoError(t, l.LoadFrom(bytes.NewReader(data)))
+
+	keys, err := l.List(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	val, found, err := l.Get(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Empty(t, val)
+}
+
+// TestSaveLoadTTL tests keys with a ttl keep their expiry time when saved and
+// loaded, and keys which expired before loading are not loaded.
+func TestSaveLoadTTL(t *testing.T) {
+	ctx := context.Background()
+	m := NewInmemDb().(*InmemDb)
+	require.NoError(t, m.Set(ctx, []byte("/a"), []byte("a")))
+	require.NoError(t, m.SetWithTTL(ctx, []byte("/b"), []byte("b"), time.Minute))
+	require.NoError(t, m.SetWithTTL(ctx, []byte("/c"), []byte("c"), 50*time.Millisecond))
+
+	var buf bytes.Buffer
+	require.NoError(t, m.SaveTo(&buf))
+	time.Sleep(100 * time.Millisecond)
+
+	l := NewInmemDb().(*InmemDb)
+	require.NoError(t, l.LoadFrom(&buf))
+	keys, err := l.List(ctx, nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, [][]byte{[]byte("/a"), []byte("/b")}, keys)
+
+	ttl, found, err := l.GetTTL(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Zero(t, ttl)
+	ttl, found, err = l.GetTTL(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, ttl > 0 && ttl < time.Minute-50*time.Millisecond, "ttl: %v", ttl)
+
+	// the loaded key is swept when it expires
+	require.Equal(t, 1, l.Sweep(time.Now().Add(time.Minute)))
+	_, found, err = l.Get(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+// TestFileDb tests persisting a database to a file.
+func TestFileDb(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "objstore-inmem-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot")
+	f, err := OpenFileDb(path, time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, f.Set(ctx, []byte("/a"), []byte("a")))
+
+	// wait for an autosave
+	require.Eventually(t, func() bool {
+		l := NewInmemDb().(*InmemDb)
+		if err := l.LoadFromFile(path); err != nil {
+			return false
+		}
+		_, found, _ := l.Get(ctx, []byte("/a"))
+		return found
+	}, 5*time.Second, time.Millisecond)
+
+	require.NoError(t, f.Set(ctx, []byte("/b"), []byte("b")))
+	require.NoError(t, db.Close(f))
+
+	f, err = OpenFileDb(path, 0)
+	require.NoError(t, err)
+	val, found, err := f.Get(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("b"), val)
+	require.NoError(t, f.Close())
+
+	// keys with a ttl keep it across a restart
+	f, err = OpenFileDb(path, 0)
+	require.NoError(t, err)
+	require.NoError(t, f.SetWithTTL(ctx, []byte("/c"), []byte("c"), time.Minute))
+	require.NoError(t, f.Close())
+	f, err = OpenFileDb(path, 0)
+	require.NoError(t, err)
+	ttl, found, err := f.GetTTL(ctx, []byte("/c"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, ttl > 0 && ttl <= time.Minute, "ttl: %v", ttl)
+	require.NoError(t, f.Close())
+
+	// only the snapshot is left in the directory
+	infos, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+}
@@ -0,0 +1,149 @@
+package inmem
+
+import (
+	"container/heap"
+	"context"
+	"time"
+
+	"github.com/Workiva/go-datastructures/trie/ctrie"
+	"github.com/aperturerobotics/objstore/db"
+)
+
+// expiryItem is an entry in the expiry queue.
+type expiryItem struct {
+	key     []byte
+	expires time.Time
+}
+
+// expiryQueue is a min-heap of keys ordered by expiry time.
+// Items may be stale if the key was overwritten or deleted.
+type expiryQueue []*expiryItem
+
+// Len returns the length of the queue.
+func (q expiryQueue) Len() int { return len(q) }
+
+// Less compares two items in the queue.
+func (q expiryQueue) Less(i, j int) bool { return q[i].expires.Before(q[j].expires) }
+
+// Swap swaps two items in the queue.
+func (q expiryQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+// Push pushes an item to the queue.
+func (q *expiryQueue) Push(x interface{}) { *q = append(*q, x.(*expiryItem)) }
+
+// Pop pops an item from the queue.
+func (q *expiryQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return item
+}
+
+// SetWithTTL sets an object in the database which expires after the ttl.
+// Expired keys are hidden immediately and removed by later writes or Sweep.
+func (m *InmemDb) SetWithTTL(ctx context.Context, key []byte, val []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return m.Set(ctx, key, val)
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	key, val = copyBytes(key), copyBytes(val)
+	expires := time.Now().Add(ttl)
+	m.insert(key, &inmemEntry{val: val, expires: expires})
+	heap.Push(&m.expiryQueue, &expiryItem{key: key, expires: expires})
+	m.watch.Emit(db.Event{Type: db.EventPut, Key: key, Value: val})
+	return nil
+}
+
+// GetTTL returns the remaining ttl of a key, zero if the key does not expire.
+func (m *InmemDb) GetTTL(ctx context.Context, key []byte) (time.Duration, bool, error) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	ttl, ok := lookupTTL(m.ct, key)
+	return ttl, ok, nil
+}
+
+// GetTTL returns the remaining ttl of a key in the snapshot.
+func (s *inmemSnapshot) GetTTL(ctx context.Context, key []byte) (time.Duration, bool, error) {
+	ttl, ok := lookupTTL(s.ct, key)
+	return ttl, ok, nil
+}
+
+// lookupTTL looks up the remaining ttl of a non-expired key in a ctrie.
+func lookupTTL(ct *ctrie.Ctrie, key []byte) (time.Duration, bool) {
+	obj, ok := ct.Lookup(key)
+	if !ok {
+		return 0, false
+	}
+
+	ent := obj.(*inmemEntry)
+	if ent.expires.IsZero() {
+		return 0, true
+	}
+
+	ttl := time.Until(ent.expires)
+	if ttl <= 0 {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// Sweep removes keys which have expired as of now.
+// Expired keys are also removed by writes, so the sweeper is only needed to
+// remove keys from a database which is not written to.
+// Returns the number of keys removed.
+func (m *InmemDb) Sweep(now time.Time) int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.sweep(now)
+}
+
+// sweep removes keys which have expired as of now.
+// Expects mtx to be locked.
+func (m *InmemDb) sweep(now time.Time) int {
+	var n int
+	for len(m.expiryQueue) != 0 && !now.Before(m.expiryQueue[0].expires) {
+		item := heap.Pop(&m.expiryQueue).(*expiryItem)
+		obj, ok := m.ct.Lookup(item.key)
+		if !ok {
+			continue
+		}
+
+		// skip if the key was overwritten since
+		if ent := obj.(*inmemEntry); ent.expired(now) {
+			m.ct.Remove(item.key)
+			m.watch.Emit(db.Event{Type: db.EventDelete, Key: item.key})
+			n++
+		}
+	}
+
+	return n
+}
+
+// RunSweeper sweeps expired keys at the interval until the context is canceled.
+func (m *InmemDb) RunSweeper(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			m.Sweep(now)
+		}
+	}
+}
+
+// _ are type assertions
+var (
+	_ db.TTLGetter = &InmemDb{}
+	_ db.TTLSetter = &InmemDb{}
+	_ db.TTLGetter = &inmemSnapshot{}
+)
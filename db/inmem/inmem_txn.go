@@ -0,0 +1,145 @@
+package inmem
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Workiva/go-datastructures/trie/ctrie"
+	"github.com/aperturerobotics/objstore/db"
+)
+
+// inmemTxn is a transaction against the in-memory database.
+// Reads are served from a snapshot taken when the transaction was created.
+// Writes are buffered and applied under the database write lock on Commit.
+type inmemTxn struct {
+	mtx       sync.Mutex
+	m         *InmemDb
+	snap      *ctrie.Ctrie
+	write     bool
+	discarded bool
+	// pending contains pending writes, a nil value indicates a delete
+	pending map[string][]byte
+}
+
+// NewTxn builds a new transaction.
+func (m *InmemDb) NewTxn(ctx context.Context, write bool) (db.Txn, error) {
+	m.mtx.RLock()
+	snap := m.ct.ReadOnlySnapshot()
+	m.mtx.RUnlock()
+
+	return &inmemTxn{
+		m:       m,
+		snap:    snap,
+		write:   write,
+		pending: make(map[string][]byte),
+	}, nil
+}
+
+// Get retrieves an object from the transaction.
+func (t *inmemTxn) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.discarded {
+		return nil, false, db.ErrTxnDiscarded
+	}
+
+	if val, ok := t.pending[string(key)]; ok {
+		if val == nil {
+			return nil, false, nil
+		}
+		return copyBytes(val), true, nil
+	}
+
+	val, ok := lookup(t.snap, key, time.Now())
+	if !ok {
+		return nil, false, nil
+	}
+
+	return copyBytes(val), true, nil
+}
+
+// Set sets an object in the transaction.
+func (t *inmemTxn) Set(ctx context.Context, key []byte, val []byte) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if err := t.checkWrite(); err != nil {
+		return err
+	}
+
+	t.pending[string(key)] = copyBytes(val)
+	return nil
+}
+
+// Delete deletes a set of keys in the transaction.
+func (t *inmemTxn) Delete(ctx context.Context, keys ...[]byte) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if err := t.checkWrite(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		t.pending[string(key)] = nil
+	}
+
+	return nil
+}
+
+// Commit atomically applies the changes in the transaction.
+func (t *inmemTxn) Commit(ctx context.Context) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.discarded {
+		return db.ErrTxnDiscarded
+	}
+	t.discarded = true
+
+	t.m.mtx.Lock()
+	defer t.m.mtx.Unlock()
+
+	events := make([]db.Event, 0, len(t.pending))
+	for k, val := range t.pending {
+		key := []byte(k)
+		if val == nil {
+			if _, ok := t.m.ct.Remove(key); ok {
+				events = append(events, db.Event{Type: db.EventDelete, Key: key})
+			}
+		} else {
+			t.m.insert(key, &inmemEntry{val: val})
+			events = append(events, db.Event{Type: db.EventPut, Key: key, Value: val})
+		}
+	}
+	t.m.watch.Emit(events...)
+
+	return nil
+}
+
+// Discard discards the transaction.
+func (t *inmemTxn) Discard() {
+	t.mtx.Lock()
+	t.discarded = true
+	t.pending = nil
+	t.mtx.Unlock()
+}
+
+// checkWrite checks if the transaction can be written to.
+// Expects mtx to be held.
+func (t *inmemTxn) checkWrite() error {
+	if t.discarded {
+		return db.ErrTxnDiscarded
+	}
+
+	if !t.write {
+		return db.ErrTxnReadOnly
+	}
+
+	return nil
+}
+
+// _ is a type assertion
+var _ db.Batcher = &InmemDb{}
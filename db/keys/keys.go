@@ -0,0 +1,260 @@
+package keys
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Type codes prefixed to encoded elements.
+// Elements of different types sort by type code.
+const (
+	codeBytes  byte = 0x01
+	codeString byte = 0x02
+	codeInt    byte = 0x10
+	codeUint   byte = 0x11
+	codeFloat  byte = 0x20
+	codeTime   byte = 0x30
+)
+
+// Byte strings are terminated with 0x00 0x01, and 0x00 bytes are escaped as
+// 0x00 0xff. The terminator sorts before any escaped or unescaped byte, so
+// shorter strings sort first, and the key of a tuple is only a prefix of the
+// keys of tuples it is a prefix of.
+const (
+	escapeByte     byte = 0x00
+	terminatorByte byte = 0x01
+	escapedZero    byte = 0xff
+)
+
+// signBit is the sign bit of a 64 bit integer.
+const signBit = 1 << 63
+
+// ErrInvalidKey is returned when decoding a key which is not a valid tuple.
+var ErrInvalidKey = errors.New("invalid tuple key")
+
+// Pack encodes a tuple of elements into a key.
+//
+// The byte order of keys matches the order of their tuples, compared element
+// by element. A tuple sorts before any tuple it is a prefix of, and the key of
+// a tuple is a prefix of exactly the keys of those tuples, so listing keys by
+// a packed prefix finds all tuples beginning with its elements.
+//
+// Elements may be strings, byte slices, signed and unsigned integers, floats,
+// and time.Time. Elements of different types sort by type, in the order:
+// []byte, string, signed integers, unsigned integers, floats, and times, so
+// the same element should always be encoded with the same type.
+func Pack(elems ...interface{}) ([]byte, error) {
+	return Append(nil, elems...)
+}
+
+// MustPack encodes a tuple of elements into a key, panicking if an element has
+// an unsupported type.
+func MustPack(elems ...interface{}) []byte {
+	key, err := Pack(elems...)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// Append appends the encoding of a tuple of elements to key.
+func Append(key []byte, elems ...interface{}) ([]byte, error) {
+	for _, elem := range elems {
+		switch v := elem.(type) {
+		case []byte:
+			key = appendBytes(append(key, codeBytes), v)
+		case string:
+			key = appendBytes(append(key, codeString), []byte(v))
+		case int:
+			key = appendInt(key, int64(v))
+		case int8:
+			key = appendInt(key, int64(v))
+		case int16:
+			key = appendInt(key, int64(v))
+		case int32:
+			key = appendInt(key, int64(v))
+		case int64:
+			key = appendInt(key, v)
+		case uint:
+			key = appendUint(key, uint64(v))
+		case uint8:
+			key = appendUint(key, uint64(v))
+		case uint16:
+			key = appendUint(key, uint64(v))
+		case uint32:
+			key = appendUint(key, uint64(v))
+		case uint64:
+			key = appendUint(key, v)
+		case float32:
+			key = appendFloat(key, float64(v))
+		case float64:
+			key = appendFloat(key, v)
+		case time.Time:
+			key = appendTime(key, v)
+		default:
+			return nil, fmt.Errorf("unsupported key element type: %T", elem)
+		}
+	}
+
+	return key, nil
+}
+
+// appendBytes appends an escaped and terminated byte string.
+func appendBytes(key, b []byte) []byte {
+	for _, c := range b {
+		key = append(key, c)
+		if c == escapeByte {
+			key = append(key, escapedZero)
+		}
+	}
+	return append(key, escapeByte, terminatorByte)
+}
+
+// appendInt appends a signed integer with the sign bit flipped, so negative
+// numbers sort first.
+func appendInt(key []byte, v int64) []byte {
+	key = append(key, codeInt)
+	return appendUint64(key, uint64(v)^signBit)
+}
+
+// appendUint appends an unsigned integer.
+func appendUint(key []byte, v uint64) []byte {
+	return appendUint64(append(key, codeUint), v)
+}
+
+// appendFloat appends a float. Positive floats have the sign bit flipped, and
+// negative floats have all bits flipped, so the IEEE 754 bits sort in order.
+func appendFloat(key []byte, v float64) []byte {
+	bits := math.Float64bits(v)
+	if bits&signBit != 0 {
+		bits = ^bits
+	} else {
+		bits ^= signBit
+	}
+	return appendUint64(append(key, codeFloat), bits)
+}
+
+// appendTime appends a time as the unix seconds followed by the nanoseconds.
+func appendTime(key []byte, t time.Time) []byte {
+	key = append(key, codeTime)
+	key = appendUint64(key, uint64(t.Unix())^signBit)
+	var nsec [4]byte
+	binary.BigEndian.PutUint32(nsec[:], uint32(t.Nanosecond()))
+	return append(key, nsec[:]...)
+}
+
+// appendUint64 appends a big-endian 64 bit integer.
+func appendUint64(key []byte, v uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	return append(key, b[:]...)
+}
+
+// Unpack decodes a key encoded by Pack into its elements.
+// Elements are decoded as []byte, string, int64, uint64, float64, or a UTC
+// time.Time, according to the type they were encoded with.
+func Unpack(key []byte) ([]interface{}, error) {
+	var elems []interface{}
+	for len(key) != 0 {
+		code := key[0]
+		key = key[1:]
+
+		var elem interface{}
+		switch code {
+		case codeBytes, codeString:
+			b, n, err := decodeBytes(key)
+			if err != nil {
+				return nil, err
+			}
+			key = key[n:]
+			if code == codeString {
+				elem = string(b)
+			} else {
+				elem = b
+			}
+		case codeInt, codeUint, codeFloat:
+			if len(key) < 8 {
+				return nil, ErrInvalidKey
+			}
+			v := binary.BigEndian.Uint64(key)
+			key = key[8:]
+			switch code {
+			case codeInt:
+				elem = int64(v ^ signBit)
+			case codeUint:
+				elem = v
+			default:
+				if v&signBit != 0 {
+					v ^= signBit
+				} else {
+					v = ^v
+				}
+				elem = math.Float64frombits(v)
+			}
+		case codeTime:
+			if len(key) < 12 {
+				return nil, ErrInvalidKey
+			}
+			sec := int64(binary.BigEndian.Uint64(key) ^ signBit)
+			nsec := int64(binary.BigEndian.Uint32(key[8:]))
+			if nsec >= int64(time.Second) {
+				return nil, ErrInvalidKey
+			}
+			key = key[12:]
+			elem = time.Unix(sec, nsec).UTC()
+		default:
+			return nil, ErrInvalidKey
+		}
+
+		elems = append(elems, elem)
+	}
+
+	return elems, nil
+}
+
+// decodeBytes decodes an escaped and terminated byte string.
+// Returns the byte string and the number of bytes consumed.
+func decodeBytes(key []byte) ([]byte, int, error) {
+	out := []byte{}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c != escapeByte {
+			out = append(out, c)
+			continue
+		}
+
+		if i+1 == len(key) {
+			break
+		}
+		switch key[i+1] {
+		case terminatorByte:
+			return out, i + 2, nil
+		case escapedZero:
+			out = append(out, escapeByte)
+			i++
+		default:
+			return nil, 0, ErrInvalidKey
+		}
+	}
+
+	return nil, 0, ErrInvalidKey
+}
+
+// Range returns the range of keys of the tuple of the elements and all tuples
+// beginning with them, for the Start and End of db.IteratorOpts.
+// Start is inclusive and end is exclusive.
+func Range(elems ...interface{}) (start []byte, end []byte, err error) {
+	start, err = Pack(elems...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 0xff is greater than any type code following the prefix
+	end = make([]byte, len(start), len(start)+1)
+	copy(end, start)
+	end = append(end, 0xff)
+	return start, end, nil
+}
@@ -0,0 +1,165 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRoundTrip tests decoding packed tuples.
+func TestRoundTrip(t *testing.T) {
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	key, err := Pack(
+		"a\x00b", []byte{0, 0xff, 1}, []byte{}, "",
+		int8(-3), int64(math.MinInt64), 42,
+		uint8(7), uint64(math.MaxUint64),
+		float32(1.5), -2.25, math.Inf(-1),
+		ts, time.Unix(-100, 5),
+	)
+	require.NoError(t, err)
+
+	elems, err := Unpack(key)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{
+		"a\x00b", []byte{0, 0xff, 1}, []byte{}, "",
+		int64(-3), int64(math.MinInt64), int64(42),
+		uint64(7), uint64(math.MaxUint64),
+		1.5, -2.25, math.Inf(-1),
+		ts, time.Unix(-100, 5).UTC(),
+	}, elems)
+
+	_, err = Pack(struct{}{})
+	require.Error(t, err)
+
+	for _, bad := range [][]byte{
+		{0x7f},
+		{codeString, 'a'},
+		{codeString, 'a', 0x00},
+		{codeString, 0x00, 0x02},
+		{codeInt, 1, 2, 3},
+		{codeTime, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff},
+	} {
+		_, err := Unpack(bad)
+		require.Equal(t, ErrInvalidKey, err, "%x", bad)
+	}
+}
+
+// TestOrder tests the byte order of keys matches the order of their tuples.
+func TestOrder(t *testing.T) {
+	ordered := [][]interface{}{
+		{[]byte{}},
+		{[]byte{0}},
+		{[]byte{0, 0}},
+		{[]byte{0, 1}},
+		{[]byte{1}},
+		{""},
+		{"a"},
+		{"a", ""},
+		{"a", "b"},
+		{"a", 1},
+		{"a\x00"},
+		{"a\x00", "b"},
+		{"a\x01"},
+		{"ab"},
+		{"b"},
+		{int64(math.MinInt64)},
+		{-256},
+		{-1},
+		{0},
+		{1},
+		{255},
+		{256},
+		{int64(math.MaxInt64)},
+		{uint(0)},
+		{uint(1)},
+		{uint64(math.MaxUint64)},
+		{math.Inf(-1)},
+		{-1e300},
+		{-1.5},
+		{-1e-300},
+		{math.Copysign(0, -1)},
+		{0.0},
+		{1e-300},
+		{1.5},
+		{1e300},
+		{math.Inf(1)},
+		{time.Unix(-1, 999999999)},
+		{time.Unix(0, 0)},
+		{time.Unix(0, 1)},
+		{time.Unix(1, 0)},
+		{time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	packed := make([][]byte, len(ordered))
+	for i, tuple := range ordered {
+		packed[i] = MustPack(tuple...)
+	}
+
+	shuffled := make([][]byte, len(packed))
+	for i := range packed {
+		shuffled[i] = packed[(i*7)%len(packed)]
+	}
+	sort.Slice(shuffled, func(i, j int) bool {
+		return bytes.Compare(shuffled[i], shuffled[j]) < 0
+	})
+	require.Equal(t, packed, shuffled)
+}
+
+// TestPrefixList tests listing and iterating tuples by a prefix tuple.
+func TestPrefixList(t *testing.T) {
+	ctx := context.Background()
+	d := inmem.NewInmemDb()
+
+	tuples := [][]interface{}{
+		{"user", "a"},
+		{"user", "a", 1},
+		{"user", "a", 2},
+		{"user", "a\x00b", 1},
+		{"user", "ab", 1},
+		{"user", "b", 1},
+		{"users", "a", 1},
+	}
+	for _, tuple := range tuples {
+		require.NoError(t, d.Set(ctx, MustPack(tuple...), nil))
+	}
+
+	keys, err := d.List(ctx, MustPack("user", "a"))
+	require.NoError(t, err)
+	var found [][]interface{}
+	for _, key := range keys {
+		elems, err := Unpack(key)
+		require.NoError(t, err)
+		found = append(found, elems)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return bytes.Compare(MustPack(found[i]...), MustPack(found[j]...)) < 0
+	})
+	require.Equal(t, [][]interface{}{
+		{"user", "a"},
+		{"user", "a", int64(1)},
+		{"user", "a", int64(2)},
+	}, found)
+
+	start, end, err := Range("user")
+	require.NoError(t, err)
+	it, err := db.NewIterator(ctx, d, db.IteratorOpts{Start: start, End: end})
+	require.NoError(t, err)
+	defer it.Close()
+
+	var n int
+	for ; it.Valid(); it.Next() {
+		elems, err := Unpack(it.Key())
+		require.NoError(t, err)
+		require.Equal(t, "user", elems[0])
+		n++
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, 6, n)
+}
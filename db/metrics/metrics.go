@@ -0,0 +1,393 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Operation label values.
+const (
+	opGet            = "get"
+	opSet            = "set"
+	opList           = "list"
+	opDelete         = "delete"
+	opCommit         = "commit"
+	opCompareAndSwap = "compare_and_swap"
+	opDropPrefix     = "drop_prefix"
+	opIterate        = "iterate"
+	opSnapshot       = "snapshot"
+	opWatch          = "watch"
+)
+
+// Config configures a metrics database.
+type Config struct {
+	// Namespace is the metric namespace, defaults to "objstore".
+	Namespace string
+	// Subsystem is the metric subsystem, defaults to "db".
+	Subsystem string
+	// Labels are constant labels added to every metric.
+	// For example: prometheus.Labels{"namespace": "objects"}
+	Labels prometheus.Labels
+	// Buckets are the latency histogram buckets in seconds.
+	// If empty, prometheus.DefBuckets is used.
+	Buckets []float64
+}
+
+// MetricsDb records metrics for operations on a db.
+// MetricsDb is a prometheus.Collector, and must be registered to be exported.
+type MetricsDb struct {
+	db db.Db
+
+	ops          *prometheus.CounterVec
+	errors       *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	bytesRead    prometheus.Counter
+	bytesWritten prometheus.Counter
+}
+
+// NewMetricsDb builds a new metrics database wrapping d.
+func NewMetricsDb(d db.Db, conf Config) *MetricsDb {
+	namespace := conf.Namespace
+	if namespace == "" {
+		namespace = "objstore"
+	}
+	subsystem := conf.Subsystem
+	if subsystem == "" {
+		subsystem = "db"
+	}
+	buckets := conf.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	opLabels := []string{"op"}
+	return &MetricsDb{
+		db: d,
+		ops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "operations_total",
+			Help:        "Number of database operations.",
+			ConstLabels: conf.Labels,
+		}, opLabels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "errors_total",
+			Help:        "Number of database operations which returned an error.",
+			ConstLabels: conf.Labels,
+		}, opLabels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "operation_duration_seconds",
+			Help:        "Latency of database operations.",
+			ConstLabels: conf.Labels,
+			Buckets:     buckets,
+		}, opLabels),
+		bytesRead: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "read_bytes_total",
+			Help:        "Number of value bytes read from the database.",
+			ConstLabels: conf.Labels,
+		}),
+		bytesWritten: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "written_bytes_total",
+			Help:        "Number of value bytes written to the database.",
+			ConstLabels: conf.Labels,
+		}),
+	}
+}
+
+// Describe sends the metric descriptors to the channel.
+func (m *MetricsDb) Describe(ch chan<- *prometheus.Desc) {
+	m.ops.Describe(ch)
+	m.errors.Describe(ch)
+	m.duration.Describe(ch)
+	m.bytesRead.Describe(ch)
+	m.bytesWritten.Describe(ch)
+}
+
+// Collect sends the current metric values to the channel.
+func (m *MetricsDb) Collect(ch chan<- prometheus.Metric) {
+	m.ops.Collect(ch)
+	m.errors.Collect(ch)
+	m.duration.Collect(ch)
+	m.bytesRead.Collect(ch)
+	m.bytesWritten.Collect(ch)
+}
+
+// observe records an operation which started at start.
+func (m *MetricsDb) observe(op string, start time.Time, err error) {
+	m.ops.WithLabelValues(op).Inc()
+	m.duration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(op).Inc()
+	}
+}
+
+// Get retrieves an object from the database.
+func (m *MetricsDb) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	start := time.Now()
+	val, found, err := m.db.Get(ctx, key)
+	m.observe(opGet, start, err)
+	m.bytesRead.Add(float64(len(val)))
+	return val, found, err
+}
+
+// Set sets an object in the database.
+func (m *MetricsDb) Set(ctx context.Context, key []byte, val []byte) error {
+	start := time.Now()
+	err := m.db.Set(ctx, key, val)
+	m.observe(opSet, start, err)
+	if err == nil {
+		m.bytesWritten.Add(float64(len(val)))
+	}
+	return err
+}
+
+// SetWithTTL sets an object in the database with a ttl.
+// Recorded as a set operation.
+func (m *MetricsDb) SetWithTTL(ctx context.Context, key []byte, val []byte, ttl time.Duration) error {
+	start := time.Now()
+	err := db.SetWithTTL(ctx, m.db, key, val, ttl)
+	m.observe(opSet, start, err)
+	if err == nil {
+		m.bytesWritten.Add(float64(len(val)))
+	}
+	return err
+}
+
+// GetTTL returns the remaining ttl of a key.
+// Recorded as a get operation.
+func (m *MetricsDb) GetTTL(ctx context.Context, key []byte) (time.Duration, bool, error) {
+	start := time.Now()
+	ttl, found, err := db.GetTTL(ctx, m.db, key)
+	m.observe(opGet, start, err)
+	return ttl, found, err
+}
+
+// List returns a list of keys with the specified prefix.
+func (m *MetricsDb) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	start := time.Now()
+	keys, err := m.db.List(ctx, prefix)
+	m.observe(opList, start, err)
+	return keys, err
+}
+
+// Delete clears a set of keys from the db.
+func (m *MetricsDb) Delete(ctx context.Context, keys ...[]byte) error {
+	start := time.Now()
+	err := m.db.Delete(ctx, keys...)
+	m.observe(opDelete, start, err)
+	return err
+}
+
+// ListPage lists a page of keys with a prefix.
+// Recorded as a list operation.
+func (m *MetricsDb) ListPage(ctx context.Context, prefix, startAfter []byte, limit int) ([][]byte, []byte, error) {
+	start := time.Now()
+	keys, next, err := db.ListPage(ctx, m.db, prefix, startAfter, limit)
+	m.observe(opList, start, err)
+	return keys, next, err
+}
+
+// CompareAndSwap sets the key to val if the current value equals old.
+func (m *MetricsDb) CompareAndSwap(ctx context.Context, key []byte, old, val []byte) (bool, error) {
+	start := time.Now()
+	swapped, err := db.CompareAndSwap(ctx, m.db, key, old, val)
+	m.observe(opCompareAndSwap, start, err)
+	if swapped {
+		m.bytesWritten.Add(float64(len(val)))
+	}
+	return swapped, err
+}
+
+// SetIfNotExists sets the key to val if the key does not exist.
+// Recorded as a compare and swap operation.
+func (m *MetricsDb) SetIfNotExists(ctx context.Context, key []byte, val []byte) (bool, error) {
+	start := time.Now()
+	set, err := db.SetIfNotExists(ctx, m.db, key, val)
+	m.observe(opCompareAndSwap, start, err)
+	if set {
+		m.bytesWritten.Add(float64(len(val)))
+	}
+	return set, err
+}
+
+// DropPrefix deletes all keys with a prefix.
+func (m *MetricsDb) DropPrefix(ctx context.Context, prefix []byte) error {
+	start := time.Now()
+	err := db.DropPrefix(ctx, m.db, prefix)
+	m.observe(opDropPrefix, start, err)
+	return err
+}
+
+// NewIterator builds a new iterator over the underlying db.
+// Building the iterator is recorded as an iterate operation, and values read
+// from it are counted.
+func (m *MetricsDb) NewIterator(ctx context.Context, opts db.IteratorOpts) (db.Iterator, error) {
+	return m.newIterator(ctx, m.db, opts)
+}
+
+// newIterator builds a new iterator over src recording metrics.
+func (m *MetricsDb) newIterator(ctx context.Context, src db.ReadOnlyDb, opts db.IteratorOpts) (db.Iterator, error) {
+	start := time.Now()
+	it, err := db.NewIterator(ctx, src, opts)
+	m.observe(opIterate, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metricsIterator{Iterator: it, m: m}, nil
+}
+
+// metricsIterator counts the bytes read from an iterator.
+type metricsIterator struct {
+	db.Iterator
+	m *MetricsDb
+}
+
+// Value returns a copy of the value at the current position.
+func (i *metricsIterator) Value() ([]byte, error) {
+	val, err := i.Iterator.Value()
+	i.m.bytesRead.Add(float64(len(val)))
+	return val, err
+}
+
+// Snapshot returns a read-only view of the database at this point in time.
+// Reads from the snapshot are recorded.
+func (m *MetricsDb) Snapshot(ctx context.Context) (db.ReadOnlyDb, func(), error) {
+	start := time.Now()
+	snap, release, err := db.Snapshot(ctx, m.db)
+	m.observe(opSnapshot, start, err)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &metricsSnapshot{m: m, snap: snap}, release, nil
+}
+
+// metricsSnapshot records metrics for reads from a snapshot.
+type metricsSnapshot struct {
+	m    *MetricsDb
+	snap db.ReadOnlyDb
+}
+
+// Get retrieves an object from the snapshot.
+func (s *metricsSnapshot) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	start := time.Now()
+	val, found, err := s.snap.Get(ctx, key)
+	s.m.observe(opGet, start, err)
+	s.m.bytesRead.Add(float64(len(val)))
+	return val, found, err
+}
+
+// List lists keys with a prefix in the snapshot.
+func (s *metricsSnapshot) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	start := time.Now()
+	keys, err := s.snap.List(ctx, prefix)
+	s.m.observe(opList, start, err)
+	return keys, err
+}
+
+// NewIterator builds a new iterator over the snapshot.
+func (s *metricsSnapshot) NewIterator(ctx context.Context, opts db.IteratorOpts) (db.Iterator, error) {
+	return s.m.newIterator(ctx, s.snap, opts)
+}
+
+// Watch watches for changes to keys with a prefix.
+// Starting the watch is recorded as a watch operation.
+func (m *MetricsDb) Watch(ctx context.Context, prefix []byte) (<-chan db.Event, error) {
+	start := time.Now()
+	ch, err := db.Watch(ctx, m.db, prefix)
+	m.observe(opWatch, start, err)
+	return ch, err
+}
+
+// NewTxn builds a new transaction against the underlying db.
+// Operations in the transaction are recorded, and Commit is recorded as a
+// commit operation.
+func (m *MetricsDb) NewTxn(ctx context.Context, write bool) (db.Txn, error) {
+	txn, err := db.NewTxn(ctx, m.db, write)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metricsTxn{m: m, txn: txn}, nil
+}
+
+// metricsTxn records metrics for operations on a txn.
+// Bytes written are counted when set in the transaction.
+type metricsTxn struct {
+	m   *MetricsDb
+	txn db.Txn
+}
+
+// Get retrieves an object from the transaction.
+func (t *metricsTxn) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	start := time.Now()
+	val, found, err := t.txn.Get(ctx, key)
+	t.m.observe(opGet, start, err)
+	t.m.bytesRead.Add(float64(len(val)))
+	return val, found, err
+}
+
+// Set sets an object in the transaction.
+func (t *metricsTxn) Set(ctx context.Context, key []byte, val []byte) error {
+	start := time.Now()
+	err := t.txn.Set(ctx, key, val)
+	t.m.observe(opSet, start, err)
+	if err == nil {
+		t.m.bytesWritten.Add(float64(len(val)))
+	}
+	return err
+}
+
+// Delete clears a set of keys in the transaction.
+func (t *metricsTxn) Delete(ctx context.Context, keys ...[]byte) error {
+	start := time.Now()
+	err := t.txn.Delete(ctx, keys...)
+	t.m.observe(opDelete, start, err)
+	return err
+}
+
+// Commit commits the transaction.
+func (t *metricsTxn) Commit(ctx context.Context) error {
+	start := time.Now()
+	err := t.txn.Commit(ctx)
+	t.m.observe(opCommit, start, err)
+	return err
+}
+
+// Discard discards the transaction.
+func (t *metricsTxn) Discard() {
+	t.txn.Discard()
+}
+
+// Close closes the underlying db.
+func (m *MetricsDb) Close() error {
+	return db.Close(m.db)
+}
+
+// _ are type assertions
+var (
+	_ db.Db                = &MetricsDb{}
+	_ db.Batcher           = &MetricsDb{}
+	_ db.Closer            = &MetricsDb{}
+	_ db.CompareAndSwapper = &MetricsDb{}
+	_ db.Iterable          = &MetricsDb{}
+	_ db.Pager             = &MetricsDb{}
+	_ db.PrefixDropper     = &MetricsDb{}
+	_ db.Snapshotter       = &MetricsDb{}
+	_ db.TTLGetter         = &MetricsDb{}
+	_ db.TTLSetter         = &MetricsDb{}
+	_ db.Watcher           = &MetricsDb{}
+	_ prometheus.Collector = &MetricsDb{}
+)
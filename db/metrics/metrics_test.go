@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aperturerobotics/objstore/db"
+	dbadger "github.com/aperturerobotics/objstore/db/badger"
+	"github.com/aperturerobotics/objstore/db/dbtest"
+	"github.com/aperturerobotics/objstore/db/inmem"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance runs the db conformance suite.
+func TestConformance(t *testing.T) {
+	t.Run("Inmem", func(t *testing.T) {
+		dbtest.RunConformance(t, func() db.Db {
+			return NewMetricsDb(inmem.NewInmemDb(), Config{})
+		})
+	})
+	t.Run("Badger", func(t *testing.T) {
+		var dbs []db.Db
+		defer func() {
+			for _, d := range dbs {
+				db.Close(d)
+			}
+		}()
+
+		dbtest.RunConformance(t, func() db.Db {
+			bdb, err := dbadger.OpenBadgerDB(dbadger.Config{InMemory: true})
+			require.NoError(t, err)
+			dbs = append(dbs, bdb)
+			return NewMetricsDb(bdb, Config{})
+		})
+	})
+}
+
+// TestMetrics tests operations are recorded.
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	m := NewMetricsDb(inmem.NewInmemDb(), Config{
+		Labels: prometheus.Labels{"namespace": "test"},
+	})
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(m))
+
+	require.NoError(t, m.Set(ctx, []byte("/a"), []byte("hello")))
+	_, _, err := m.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	_, _, err = m.Get(ctx, []byte("/missing"))
+	require.NoError(t, err)
+	_, err = m.List(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, m.Delete(ctx, []byte("/a")))
+
+	canceledCtx, ctxCancel := context.WithCancel(ctx)
+	ctxCancel()
+	_, err = m.List(canceledCtx, nil)
+	require.True(t, errors.Is(err, context.Canceled))
+
+	require.Equal(t, 1.0, testutil.ToFloat64(m.ops.WithLabelValues(opSet)))
+	require.Equal(t, 2.0, testutil.ToFloat64(m.ops.WithLabelValues(opGet)))
+	require.Equal(t, 2.0, testutil.ToFloat64(m.ops.WithLabelValues(opList)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.ops.WithLabelValues(opDelete)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.errors.WithLabelValues(opList)))
+	require.Equal(t, 0.0, testutil.ToFloat64(m.errors.WithLabelValues(opGet)))
+	require.Equal(t, 5.0, testutil.ToFloat64(m.bytesRead))
+	require.Equal(t, 5.0, testutil.ToFloat64(m.bytesWritten))
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	require.NotEmpty(t, mfs)
+	for _, mf := range mfs {
+		for _, metric := range mf.GetMetric() {
+			require.Equal(t, "namespace", metric.GetLabel()[0].GetName())
+			require.Equal(t, "test", metric.GetLabel()[0].GetValue())
+		}
+	}
+}
+
+// TestCapabilities tests capabilities of the underlying db are recorded.
+func TestCapabilities(t *testing.T) {
+	ctx := context.Background()
+	m := NewMetricsDb(inmem.NewInmemDb(), Config{})
+
+	ch, err := m.Watch(ctx, []byte("/"))
+	require.NoError(t, err)
+	set, err := m.SetIfNotExists(ctx, []byte("/a"), []byte("a"))
+	require.NoError(t, err)
+	require.True(t, set)
+	swapped, err := m.CompareAndSwap(ctx, []byte("/a"), []byte("a"), []byte("b"))
+	require.NoError(t, err)
+	require.True(t, swapped)
+	require.Equal(t, []byte("a"), (<-ch).Value)
+
+	snap, release, err := m.Snapshot(ctx)
+	require.NoError(t, err)
+	defer release()
+	it, err := db.NewIterator(ctx, snap, db.IteratorOpts{})
+	require.NoError(t, err)
+	require.True(t, it.Valid())
+	val, err := it.Value()
+	require.NoError(t, err)
+	require.Equal(t, []byte("b"), val)
+	require.NoError(t, it.Close())
+
+	require.NoError(t, m.DropPrefix(ctx, []byte("/")))
+	_, found, err := snap.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+
+	require.Equal(t, 2.0, testutil.ToFloat64(m.ops.WithLabelValues(opCompareAndSwap)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.ops.WithLabelValues(opDropPrefix)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.ops.WithLabelValues(opIterate)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.ops.WithLabelValues(opSnapshot)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.ops.WithLabelValues(opWatch)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.ops.WithLabelValues(opGet)))
+	require.Equal(t, 2.0, testutil.ToFloat64(m.bytesRead))
+	require.Equal(t, 2.0, testutil.ToFloat64(m.bytesWritten))
+}
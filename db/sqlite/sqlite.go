@@ -0,0 +1,159 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/aperturerobotics/objstore/db"
+)
+
+// SqliteDB implements Db with a SQLite table.
+// Keys and values are stored as BLOBs in a table keyed by the key column, so
+// the primary key index serves both lookups and prefix range scans.
+type SqliteDB struct {
+	*sql.DB
+	table string
+}
+
+// NewSqliteDB builds a new SQLite database, creating the table if necessary.
+// The caller is expected to have opened sdb with a SQLite driver.
+func NewSqliteDB(ctx context.Context, sdb *sql.DB, table string) (db.Db, error) {
+	d := &SqliteDB{DB: sdb, table: quoteIdent(table)}
+	_, err := sdb.ExecContext(
+		ctx,
+		"CREATE TABLE IF NOT EXISTS "+d.table+
+			" (key BLOB NOT NULL PRIMARY KEY, value BLOB NOT NULL) WITHOUT ROWID",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// quoteIdent quotes a SQL identifier.
+func quoteIdent(id string) string {
+	return `"` + strings.Replace(id, `"`, `""`, -1) + `"`
+}
+
+// querier is the common interface of sql.DB and sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Get retrieves an object from the database.
+// Not found should return nil, nil
+func (d *SqliteDB) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	return d.get(ctx, d.DB, key)
+}
+
+// get retrieves an object with a querier.
+func (d *SqliteDB) get(ctx context.Context, q querier, key []byte) ([]byte, bool, error) {
+	var val []byte
+	err := q.QueryRowContext(
+		ctx,
+		"SELECT value FROM "+d.table+" WHERE key = ?",
+		key,
+	).Scan(&val)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	if val == nil {
+		val = []byte{}
+	}
+	return val, true, nil
+}
+
+// Set sets an object in the database.
+func (d *SqliteDB) Set(ctx context.Context, key []byte, val []byte) error {
+	return d.set(ctx, d.DB, key, val)
+}
+
+// set sets an object with a querier.
+func (d *SqliteDB) set(ctx context.Context, q querier, key []byte, val []byte) error {
+	if val == nil {
+		val = []byte{}
+	}
+
+	_, err := q.ExecContext(
+		ctx,
+		"INSERT OR REPLACE INTO "+d.table+" (key, value) VALUES (?, ?)",
+		key,
+		val,
+	)
+	return err
+}
+
+// List lists keys in the database.
+// The prefix is converted to a key range to use the primary key index.
+func (d *SqliteDB) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	query := "SELECT key FROM " + d.table + " WHERE key >= ?"
+	args := []interface{}{prefix}
+	if prefix == nil {
+		args[0] = []byte{}
+	}
+	if end := db.PrefixEnd(prefix); end != nil {
+		query += " AND key < ?"
+		args = append(args, end)
+	}
+	query += " ORDER BY key"
+
+	rows, err := d.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vals [][]byte
+	for rows.Next() {
+		var key []byte
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		vals = append(vals, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// Delete deletes a set of keys from the db.
+func (d *SqliteDB) Delete(ctx context.Context, keys ...[]byte) error {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := d.delete(ctx, tx, keys...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// delete deletes a set of keys with a querier.
+func (d *SqliteDB) delete(ctx context.Context, q querier, keys ...[]byte) error {
+	for _, key := range keys {
+		_, err := q.ExecContext(ctx, "DELETE FROM "+d.table+" WHERE key = ?", key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// _ are type assertions
+var (
+	_ db.ClosableDb = &SqliteDB{}
+)
@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/dbtest"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// TestConformance runs the db conformance suite.
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "objstore-sqlite-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	var dbs []*sql.DB
+	defer func() {
+		for _, sdb := range dbs {
+			sdb.Close()
+		}
+	}()
+
+	dbtest.RunConformance(t, func() db.Db {
+		f, err := ioutil.TempFile(dir, "sqlite-")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		f.Close()
+
+		sdb, err := sql.Open("sqlite3", f.Name())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		dbs = append(dbs, sdb)
+
+		d, err := NewSqliteDB(context.Background(), sdb, "objstore")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return d
+	})
+}
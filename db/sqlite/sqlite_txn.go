@@ -0,0 +1,61 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/aperturerobotics/objstore/db"
+)
+
+// NewTxn builds a new transaction backed by a SQL transaction.
+func (d *SqliteDB) NewTxn(ctx context.Context, write bool) (db.Txn, error) {
+	tx, err := d.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: !write})
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqliteTxn{d: d, tx: tx, write: write}, nil
+}
+
+// sqliteTxn implements db.Txn with a SQL transaction.
+type sqliteTxn struct {
+	d     *SqliteDB
+	tx    *sql.Tx
+	write bool
+}
+
+// Get retrieves an object from the transaction.
+func (t *sqliteTxn) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	return t.d.get(ctx, t.tx, key)
+}
+
+// Set sets an object in the transaction.
+func (t *sqliteTxn) Set(ctx context.Context, key []byte, val []byte) error {
+	if !t.write {
+		return db.ErrTxnReadOnly
+	}
+
+	return t.d.set(ctx, t.tx, key, val)
+}
+
+// Delete deletes a set of keys in the transaction.
+func (t *sqliteTxn) Delete(ctx context.Context, keys ...[]byte) error {
+	if !t.write {
+		return db.ErrTxnReadOnly
+	}
+
+	return t.d.delete(ctx, t.tx, keys...)
+}
+
+// Commit commits the transaction.
+func (t *sqliteTxn) Commit(ctx context.Context) error {
+	return t.tx.Commit()
+}
+
+// Discard discards the transaction.
+func (t *sqliteTxn) Discard() {
+	_ = t.tx.Rollback()
+}
+
+// _ is a type assertion
+var _ db.Batcher = &SqliteDB{}
@@ -0,0 +1,274 @@
+package tiered
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+)
+
+// Config configures a tiered database.
+type Config struct {
+	// WriteThrough writes to the lower store synchronously.
+	// Otherwise writes are kept in the upper store until they are flushed.
+	WriteThrough bool
+}
+
+// dirtyEntry is a pending write which has not been flushed to the lower store.
+type dirtyEntry struct {
+	// tombstone indicates the key was deleted.
+	tombstone bool
+	// seq is the sequence number of the write.
+	seq uint64
+}
+
+// TieredDb is a db composed of an upper and lower store.
+//
+// Reads fall through to the lower store and are promoted to the upper store.
+// Writes go to the upper store, and are written to the lower store either
+// synchronously or when flushed. Deleted keys are recorded as tombstones
+// until they are flushed. Pending writes are held in memory, and are lost if
+// the process exits before they are flushed.
+type TieredDb struct {
+	upper, lower db.Db
+	conf         Config
+
+	// flushMtx serializes flushes
+	flushMtx sync.Mutex
+	// mtx guards the fields below
+	mtx sync.Mutex
+	// seq is incremented on every write
+	seq   uint64
+	dirty map[string]dirtyEntry
+}
+
+// NewTieredDb builds a new tiered database.
+func NewTieredDb(upper, lower db.Db, conf Config) *TieredDb {
+	return &TieredDb{
+		upper: upper,
+		lower: lower,
+		conf:  conf,
+		dirty: make(map[string]dirtyEntry),
+	}
+}
+
+// Pending returns the number of writes which have not been flushed.
+func (t *TieredDb) Pending() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return len(t.dirty)
+}
+
+// Get retrieves an object from the database.
+func (t *TieredDb) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	t.mtx.Lock()
+	ent, isDirty := t.dirty[string(key)]
+	seq := t.seq
+	t.mtx.Unlock()
+	if isDirty && ent.tombstone {
+		return nil, false, nil
+	}
+
+	val, found, err := t.upper.Get(ctx, key)
+	if err != nil || found || isDirty {
+		return val, found, err
+	}
+
+	val, found, err = t.lower.Get(ctx, key)
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	// promote if there were no writes while reading
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.seq == seq {
+		if err := t.upper.Set(ctx, key, val); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return val, true, nil
+}
+
+// Set sets an object in the database.
+// In write-through mode both stores are written while holding mtx, so
+// concurrent writes to a key are applied to both in the same order.
+func (t *TieredDb) Set(ctx context.Context, key []byte, val []byte) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.conf.WriteThrough {
+		if err := t.lower.Set(ctx, key, val); err != nil {
+			return err
+		}
+	}
+	if err := t.upper.Set(ctx, key, val); err != nil {
+		return err
+	}
+	t.markDirty(key, false)
+	return nil
+}
+
+// List returns a list of keys with the specified prefix.
+// The keys from both stores are merged, excluding deleted keys.
+func (t *TieredDb) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	lowerKeys, err := t.lower.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	upperKeys, err := t.upper.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	seen := make(map[string]struct{}, len(upperKeys))
+	var keys [][]byte
+	for _, ks := range [][][]byte{upperKeys, lowerKeys} {
+		for _, key := range ks {
+			skey := string(key)
+			if _, ok := seen[skey]; ok {
+				continue
+			}
+			seen[skey] = struct{}{}
+			if ent, ok := t.dirty[skey]; ok && ent.tombstone {
+				continue
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// Delete clears a set of keys from the db.
+func (t *TieredDb) Delete(ctx context.Context, keys ...[]byte) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.conf.WriteThrough {
+		if err := t.lower.Delete(ctx, keys...); err != nil {
+			return err
+		}
+	}
+	if err := t.upper.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		t.markDirty(key, true)
+	}
+	return nil
+}
+
+// markDirty records a write to a key.
+// In write-through mode the write is already in the lower store, so any
+// pending write to the key is cleared instead. Expects mtx to be locked.
+func (t *TieredDb) markDirty(key []byte, tombstone bool) {
+	t.seq++
+	if t.conf.WriteThrough {
+		delete(t.dirty, string(key))
+		return
+	}
+
+	t.dirty[string(key)] = dirtyEntry{tombstone: tombstone, seq: t.seq}
+}
+
+// Flush writes pending writes to the lower store.
+// Writes are applied in a single transaction if the lower store is a Batcher.
+func (t *TieredDb) Flush(ctx context.Context) error {
+	t.flushMtx.Lock()
+	defer t.flushMtx.Unlock()
+
+	t.mtx.Lock()
+	pending := make(map[string]dirtyEntry, len(t.dirty))
+	for key, ent := range t.dirty {
+		pending[key] = ent
+	}
+	t.mtx.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	txn, err := db.NewTxn(ctx, t.lower, true)
+	if err != nil {
+		return err
+	}
+	defer txn.Discard()
+
+	for skey, ent := range pending {
+		key := []byte(skey)
+		if !ent.tombstone {
+			val, found, err := t.upper.Get(ctx, key)
+			if err != nil {
+				return err
+			}
+			if found {
+				if err := txn.Set(ctx, key, val); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if err := txn.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	if err := txn.Commit(ctx); err != nil {
+		return err
+	}
+
+	// clear entries which were not written again while flushing
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for key, ent := range pending {
+		if t.dirty[key].seq == ent.seq {
+			delete(t.dirty, key)
+		}
+	}
+
+	return nil
+}
+
+// RunFlusher flushes at the interval until the context is canceled or a
+// flush fails, returning the error.
+func (t *TieredDb) RunFlusher(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := t.Flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Close flushes pending writes, then closes the upper and lower stores.
+// The stores are closed even if the flush fails.
+func (t *TieredDb) Close() error {
+	err := t.Flush(context.Background())
+	for _, d := range []db.Db{t.upper, t.lower} {
+		if cerr := db.Close(d); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// _ are type assertions
+var (
+	_ db.Db     = &TieredDb{}
+	_ db.Closer = &TieredDb{}
+)
@@ -0,0 +1,132 @@
+package tiered
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/dbtest"
+	"github.com/aperturerobotics/objstore/db/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance runs the db conformance suite.
+func TestConformance(t *testing.T) {
+	t.Run("WriteBack", func(t *testing.T) {
+		dbtest.RunConformance(t, func() db.Db {
+			return NewTieredDb(inmem.NewInmemDb(), inmem.NewInmemDb(), Config{})
+		})
+	})
+	t.Run("WriteThrough", func(t *testing.T) {
+		dbtest.RunConformance(t, func() db.Db {
+			return NewTieredDb(inmem.NewInmemDb(), inmem.NewInmemDb(), Config{WriteThrough: true})
+		})
+	})
+}
+
+// TestFlush tests promotion, tombstones and flushing.
+func TestFlush(t *testing.T) {
+	ctx := context.Background()
+	upper, lower := inmem.NewInmemDb(), inmem.NewInmemDb()
+	require.NoError(t, lower.Set(ctx, []byte("/a"), []byte("a")))
+	require.NoError(t, lower.Set(ctx, []byte("/b"), []byte("b")))
+	td := NewTieredDb(upper, lower, Config{})
+
+	// reads are promoted
+	val, found, err := td.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("a"), val)
+	_, found, err = upper.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, 0, td.Pending())
+
+	// writes are held until flushed
+	require.NoError(t, td.Set(ctx, []byte("/c"), []byte("c")))
+	require.NoError(t, td.Delete(ctx, []byte("/b")))
+	require.Equal(t, 2, td.Pending())
+	_, found, err = td.Get(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.False(t, found)
+	keys, err := td.List(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	_, found, err = lower.Get(ctx, []byte("/c"))
+	require.NoError(t, err)
+	require.False(t, found)
+	_, found, err = lower.Get(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.True(t, found)
+
+	require.NoError(t, td.Flush(ctx))
+	require.Equal(t, 0, td.Pending())
+	val, found, err = lower.Get(ctx, []byte("/c"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("c"), val)
+	_, found, err = lower.Get(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+// closeDb records if it was closed.
+type closeDb struct {
+	db.Db
+	closed bool
+}
+
+// Close marks the db as closed.
+func (d *closeDb) Close() error {
+	d.closed = true
+	return nil
+}
+
+// TestClose tests closing flushes pending writes and closes both stores.
+func TestClose(t *testing.T) {
+	ctx := context.Background()
+	upper := &closeDb{Db: inmem.NewInmemDb()}
+	lower := &closeDb{Db: inmem.NewInmemDb()}
+	d := NewTieredDb(upper, lower, Config{})
+	require.NoError(t, d.Set(ctx, []byte("/a"), []byte("a")))
+
+	require.NoError(t, db.Close(d))
+	require.True(t, upper.closed)
+	require.True(t, lower.closed)
+	_, found, err := lower.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+}
+
+// TestWriteThroughOrder tests concurrent writes to a key leave both stores
+// with the same value.
+func TestWriteThroughOrder(t *testing.T) {
+	ctx := context.Background()
+	upper, lower := inmem.NewInmemDb(), inmem.NewInmemDb()
+	d := NewTieredDb(upper, lower, Config{WriteThrough: true})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				val := []byte(fmt.Sprintf("%d-%d", i, j))
+				if err := d.Set(ctx, []byte("/a"), val); err != nil {
+					t.Error(err.Error())
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	upperVal, _, err := upper.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	lowerVal, _, err := lower.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.Equal(t, upperVal, lowerVal)
+}
@@ -0,0 +1,429 @@
+package versioned
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+)
+
+// Prefixes of keys in the underlying db.
+const (
+	// dataPrefix prefixes the current value of keys.
+	dataPrefix byte = 'd'
+	// historyPrefix prefixes version records, followed by the uvarint length
+	// of the key, the key, and the big-endian version.
+	historyPrefix byte = 'h'
+)
+
+// seqKey is the key of the last assigned version in the underlying db.
+var seqKey = []byte("s")
+
+// flagDeleted marks a version record as a deletion.
+const flagDeleted byte = 1
+
+// recordHeaderLen is the length of the version record header.
+// The header is a flags byte followed by the big-endian unix nano timestamp.
+const recordHeaderLen = 9
+
+// ErrUnknownVersion is returned when reverting to a version which was never
+// assigned, or which has been pruned from the history of the key.
+var ErrUnknownVersion = errors.New("unknown version")
+
+// ErrInvalidRecord is returned when a stored version record cannot be decoded.
+var ErrInvalidRecord = errors.New("invalid version record")
+
+// Config configures a versioned database.
+type Config struct {
+	// MaxVersions is the maximum number of versions kept per key.
+	// If zero, the number of versions is not limited.
+	MaxVersions int
+	// MaxAge is the maximum age of versions to keep.
+	// The latest version of a key is kept regardless of age, unless it is a
+	// deletion. If zero, versions do not expire.
+	MaxAge time.Duration
+}
+
+// Version is a version of a key.
+type Version struct {
+	// Version is the version number. Version numbers increase monotonically
+	// across all keys in the database.
+	Version uint64
+	// Timestamp is the time the version was written.
+	Timestamp time.Time
+	// Deleted indicates the key was deleted in this version.
+	Deleted bool
+	// Value is the value of the key, nil if deleted.
+	Value []byte
+}
+
+// VersionedDb keeps the history of every key in a db.
+// Every write is assigned a version number, and older versions are kept until
+// they are pruned according to the Config.
+type VersionedDb struct {
+	db   db.Db
+	conf Config
+	now  func() time.Time
+
+	// mtx serializes writes
+	mtx sync.Mutex
+	seq uint64
+}
+
+// NewVersionedDb builds a new versioned database wrapping d.
+// The underlying db must only be written to through the VersionedDb.
+func NewVersionedDb(ctx context.Context, d db.Db, conf Config) (*VersionedDb, error) {
+	seqData, found, err := d.Get(ctx, seqKey)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &VersionedDb{db: d, conf: conf, now: time.Now}
+	if found {
+		if len(seqData) != 8 {
+			return nil, ErrInvalidRecord
+		}
+		v.seq = binary.BigEndian.Uint64(seqData)
+	}
+
+	return v, nil
+}
+
+// dataKey returns the key of the current value of a key.
+func dataKey(key []byte) []byte {
+	return append([]byte{dataPrefix}, key...)
+}
+
+// historyKeyPrefix returns the prefix of the version records of a key.
+func historyKeyPrefix(key []byte) []byte {
+	out := make([]byte, 1+binary.MaxVarintLen64, 1+binary.MaxVarintLen64+len(key)+8)
+	out[0] = historyPrefix
+	n := binary.PutUvarint(out[1:], uint64(len(key)))
+	return append(out[:1+n], key...)
+}
+
+// historyKey returns the key of a version record.
+func historyKey(key []byte, version uint64) []byte {
+	out := historyKeyPrefix(key)
+	var ver [8]byte
+	binary.BigEndian.PutUint64(ver[:], version)
+	return append(out, ver[:]...)
+}
+
+// encodeRecord encodes a version record.
+func encodeRecord(ts time.Time, deleted bool, val []byte) []byte {
+	out := make([]byte, recordHeaderLen, recordHeaderLen+len(val))
+	if deleted {
+		out[0] = flagDeleted
+	}
+	binary.BigEndian.PutUint64(out[1:], uint64(ts.UnixNano()))
+	return append(out, val...)
+}
+
+// decodeRecord decodes a version record.
+func decodeRecord(version uint64, data []byte) (*Version, error) {
+	if len(data) < recordHeaderLen {
+		return nil, ErrInvalidRecord
+	}
+
+	v := &Version{
+		Version:   version,
+		Timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(data[1:]))),
+		Deleted:   data[0]&flagDeleted != 0,
+	}
+	if !v.Deleted {
+		v.Value = data[recordHeaderLen:]
+	}
+	return v, nil
+}
+
+// Get retrieves the current value of a key.
+func (v *VersionedDb) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	return v.db.Get(ctx, dataKey(key))
+}
+
+// Set sets an object in the database, recording a new version.
+func (v *VersionedDb) Set(ctx context.Context, key []byte, val []byte) error {
+	return v.write(ctx, key, false, val)
+}
+
+// List returns a list of keys with the specified prefix.
+func (v *VersionedDb) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	keys, err := v.db.List(ctx, dataKey(prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, key := range keys {
+		keys[i] = key[1:]
+	}
+	return keys, nil
+}
+
+// Delete clears a set of keys from the db, recording a new version for each
+// key which existed.
+func (v *VersionedDb) Delete(ctx context.Context, keys ...[]byte) error {
+	for _, key := range keys {
+		_, found, err := v.db.Get(ctx, dataKey(key))
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+
+		if err := v.write(ctx, key, true, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write writes a new version of a key, then prunes the key history.
+func (v *VersionedDb) write(ctx context.Context, key []byte, deleted bool, val []byte) error {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	txn, err := db.NewTxn(ctx, v.db, true)
+	if err != nil {
+		return err
+	}
+	defer txn.Discard()
+
+	version := v.seq + 1
+	if deleted {
+		err = txn.Delete(ctx, dataKey(key))
+	} else {
+		err = txn.Set(ctx, dataKey(key), val)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := txn.Set(ctx, historyKey(key, version), encodeRecord(v.now(), deleted, val)); err != nil {
+		return err
+	}
+
+	var seqData [8]byte
+	binary.BigEndian.PutUint64(seqData[:], version)
+	if err := txn.Set(ctx, seqKey, seqData[:]); err != nil {
+		return err
+	}
+
+	if err := txn.Commit(ctx); err != nil {
+		return err
+	}
+	v.seq = version
+
+	return v.pruneKey(ctx, key)
+}
+
+// History returns the retained versions of a key, oldest first.
+func (v *VersionedDb) History(ctx context.Context, key []byte) ([]*Version, error) {
+	prefix := historyKeyPrefix(key)
+	it, err := db.NewIterator(ctx, v.db, db.IteratorOpts{Prefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var versions []*Version
+	for ; it.Valid(); it.Next() {
+		hkey := it.Key()
+		if len(hkey) != len(prefix)+8 {
+			return nil, ErrInvalidRecord
+		}
+
+		data, err := it.Value()
+		if err != nil {
+			return nil, err
+		}
+
+		ver, err := decodeRecord(binary.BigEndian.Uint64(hkey[len(prefix):]), data)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, ver)
+	}
+
+	return versions, it.Err()
+}
+
+// GetAt retrieves the value of a key as of a version.
+// Returns not found if the key did not exist at the version, or if the
+// versions before it have been pruned.
+func (v *VersionedDb) GetAt(ctx context.Context, key []byte, version uint64) ([]byte, bool, error) {
+	opts := db.IteratorOpts{
+		Prefix:  historyKeyPrefix(key),
+		Reverse: true,
+		Limit:   1,
+	}
+	if version != math.MaxUint64 {
+		opts.End = historyKey(key, version+1)
+	}
+
+	it, err := db.NewIterator(ctx, v.db, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	defer it.Close()
+
+	if !it.Valid() {
+		return nil, false, it.Err()
+	}
+
+	data, err := it.Value()
+	if err != nil {
+		return nil, false, err
+	}
+
+	ver, err := decodeRecord(version, data)
+	if err != nil || ver.Deleted {
+		return nil, false, err
+	}
+	return ver.Value, true, nil
+}
+
+// Revert sets a key to its value as of a version, recording a new version.
+// If the key did not exist at the version, it is deleted. If versions may have
+// been pruned and the oldest retained version of the key is newer than the
+// version, ErrUnknownVersion is returned.
+func (v *VersionedDb) Revert(ctx context.Context, key []byte, version uint64) error {
+	v.mtx.Lock()
+	seq := v.seq
+	v.mtx.Unlock()
+	if version == 0 || version > seq {
+		return ErrUnknownVersion
+	}
+
+	val, found, err := v.GetAt(ctx, key, version)
+	if err != nil {
+		return err
+	}
+	if found {
+		return v.Set(ctx, key, val)
+	}
+
+	if v.conf.MaxVersions > 0 || v.conf.MaxAge > 0 {
+		oldest, ok, err := v.oldestVersion(ctx, key)
+		if err != nil {
+			return err
+		}
+		if ok && oldest > version {
+			return ErrUnknownVersion
+		}
+	}
+
+	return v.Delete(ctx, key)
+}
+
+// oldestVersion returns the oldest retained version number of a key.
+func (v *VersionedDb) oldestVersion(ctx context.Context, key []byte) (uint64, bool, error) {
+	prefix := historyKeyPrefix(key)
+	it, err := db.NewIterator(ctx, v.db, db.IteratorOpts{Prefix: prefix, Limit: 1})
+	if err != nil {
+		return 0, false, err
+	}
+	defer it.Close()
+
+	if !it.Valid() {
+		return 0, false, it.Err()
+	}
+
+	hkey := it.Key()
+	if len(hkey) != len(prefix)+8 {
+		return 0, false, ErrInvalidRecord
+	}
+	return binary.BigEndian.Uint64(hkey[len(prefix):]), true, nil
+}
+
+// Prune removes versions of all keys according to the Config.
+// Versions are also pruned when a key is written, Prune is needed to expire
+// versions of keys which are no longer written to.
+func (v *VersionedDb) Prune(ctx context.Context) error {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	// collect the distinct keys with history
+	var keys [][]byte
+	err := db.ForEachKey(ctx, v.db, []byte{historyPrefix}, func(hkey []byte) error {
+		keyLen, n := binary.Uvarint(hkey[1:])
+		if n <= 0 || uint64(len(hkey)-1-n) != keyLen+8 {
+			return ErrInvalidRecord
+		}
+
+		key := hkey[1+n : 1+n+int(keyLen)]
+		if len(keys) == 0 || !bytes.Equal(keys[len(keys)-1], key) {
+			keys = append(keys, append([]byte(nil), key...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := v.pruneKey(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pruneKey removes versions of a key according to the Config.
+// Expects mtx to be locked.
+func (v *VersionedDb) pruneKey(ctx context.Context, key []byte) error {
+	if v.conf.MaxVersions <= 0 && v.conf.MaxAge <= 0 {
+		return nil
+	}
+
+	versions, err := v.History(ctx, key)
+	if err != nil || len(versions) == 0 {
+		return err
+	}
+
+	var cutoff time.Time
+	if v.conf.MaxAge > 0 {
+		cutoff = v.now().Add(-v.conf.MaxAge)
+	}
+
+	var del [][]byte
+	last := len(versions) - 1
+	for i, ver := range versions {
+		expired := !cutoff.IsZero() && ver.Timestamp.Before(cutoff)
+		if i == last {
+			// the latest version is kept unless it is an expired deletion
+			if expired && ver.Deleted {
+				del = append(del, historyKey(key, ver.Version))
+			}
+			break
+		}
+
+		if expired || (v.conf.MaxVersions > 0 && last-i >= v.conf.MaxVersions) {
+			del = append(del, historyKey(key, ver.Version))
+		}
+	}
+
+	if len(del) == 0 {
+		return nil
+	}
+	return v.db.Delete(ctx, del...)
+}
+
+// Close closes the underlying db.
+func (v *VersionedDb) Close() error {
+	return db.Close(v.db)
+}
+
+// _ are type assertions
+var (
+	_ db.Db     = &VersionedDb{}
+	_ db.Closer = &VersionedDb{}
+)
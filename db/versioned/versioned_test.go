@@ -0,0 +1,114 @@
+package versioned
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/dbtest"
+	"github.com/aperturerobotics/objstore/db/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance runs the db conformance suite.
+func TestConformance(t *testing.T) {
+	dbtest.RunConformance(t, func() db.Db {
+		v, err := NewVersionedDb(context.Background(), inmem.NewInmemDb(), Config{MaxVersions: 2})
+		require.NoError(t, err)
+		return v
+	})
+}
+
+// TestHistory tests reading and reverting to previous versions.
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	inner := inmem.NewInmemDb()
+	v, err := NewVersionedDb(ctx, inner, Config{})
+	require.NoError(t, err)
+
+	key := []byte("/config")
+	require.NoError(t, v.Set(ctx, key, []byte("a")))
+	require.NoError(t, v.Set(ctx, []byte("/other"), []byte("x")))
+	require.NoError(t, v.Set(ctx, key, []byte("b")))
+	require.NoError(t, v.Delete(ctx, key))
+
+	hist, err := v.History(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, hist, 3)
+	require.Equal(t, uint64(1), hist[0].Version)
+	require.Equal(t, []byte("a"), hist[0].Value)
+	require.Equal(t, uint64(3), hist[1].Version)
+	require.True(t, hist[2].Deleted)
+
+	val, found, err := v.GetAt(ctx, key, 2)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("a"), val)
+
+	_, found, err = v.GetAt(ctx, key, 4)
+	require.NoError(t, err)
+	require.False(t, found)
+	_, found, err = v.GetAt(ctx, []byte("/other"), math.MaxUint64)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	require.Equal(t, ErrUnknownVersion, v.Revert(ctx, key, 5))
+	require.NoError(t, v.Revert(ctx, key, 1))
+	val, found, err = v.Get(ctx, key)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("a"), val)
+
+	// the version sequence is resumed when reopened
+	v, err = NewVersionedDb(ctx, inner, Config{})
+	require.NoError(t, err)
+	require.NoError(t, v.Set(ctx, key, []byte("c")))
+	hist, err = v.History(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), hist[len(hist)-1].Version)
+}
+
+// TestPrune tests pruning versions by count and age.
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	v, err := NewVersionedDb(ctx, inmem.NewInmemDb(), Config{
+		MaxVersions: 2,
+		MaxAge:      time.Hour,
+	})
+	require.NoError(t, err)
+	v.now = func() time.Time { return now }
+
+	for _, val := range []string{"a", "b", "c"} {
+		require.NoError(t, v.Set(ctx, []byte("/a"), []byte(val)))
+	}
+	require.NoError(t, v.Set(ctx, []byte("/b"), []byte("b")))
+	require.NoError(t, v.Delete(ctx, []byte("/b")))
+
+	hist, err := v.History(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.Len(t, hist, 2)
+	require.Equal(t, []byte("b"), hist[0].Value)
+
+	// reverting to a pruned version does not delete the key
+	require.Equal(t, ErrUnknownVersion, v.Revert(ctx, []byte("/a"), 1))
+	val, found, err := v.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("c"), val)
+
+	// expire everything but the latest version of live keys
+	now = now.Add(2 * time.Hour)
+	require.NoError(t, v.Prune(ctx))
+
+	hist, err = v.History(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.Len(t, hist, 1)
+	require.Equal(t, []byte("c"), hist[0].Value)
+
+	hist, err = v.History(ctx, []byte("/b"))
+	require.NoError(t, err)
+	require.Len(t, hist, 0)
+}
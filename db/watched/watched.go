@@ -0,0 +1,225 @@
+package watched
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aperturerobotics/objstore/db"
+)
+
+// WatchedDb emits change events for writes made through it.
+// It adds watching to databases without native support. Writes made directly
+// to the underlying db do not emit events.
+type WatchedDb struct {
+	db db.Db
+	// mtx is held across each write and emitting its events, so events are
+	// emitted in the order the writes were applied.
+	mtx   sync.Mutex
+	watch db.WatchHub
+}
+
+// NewWatchedDb builds a new watched database wrapping d.
+func NewWatchedDb(d db.Db) *WatchedDb {
+	return &WatchedDb{db: d}
+}
+
+// Watch watches for changes to keys with a prefix.
+func (w *WatchedDb) Watch(ctx context.Context, prefix []byte) (<-chan db.Event, error) {
+	return w.watch.Watch(ctx, prefix)
+}
+
+// Get retrieves an object from the database.
+func (w *WatchedDb) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	return w.db.Get(ctx, key)
+}
+
+// Set sets an object in the database.
+func (w *WatchedDb) Set(ctx context.Context, key []byte, val []byte) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if err := w.db.Set(ctx, key, val); err != nil {
+		return err
+	}
+
+	w.emitPut(key, val)
+	return nil
+}
+
+// SetWithTTL sets an object in the database with a ttl.
+// Expiry does not emit events.
+func (w *WatchedDb) SetWithTTL(ctx context.Context, key []byte, val []byte, ttl time.Duration) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if err := db.SetWithTTL(ctx, w.db, key, val, ttl); err != nil {
+		return err
+	}
+
+	w.emitPut(key, val)
+	return nil
+}
+
+// GetTTL returns the remaining ttl of a key.
+func (w *WatchedDb) GetTTL(ctx context.Context, key []byte) (time.Duration, bool, error) {
+	return db.GetTTL(ctx, w.db, key)
+}
+
+// List returns a list of keys with the specified prefix.
+func (w *WatchedDb) List(ctx context.Context, prefix []byte) ([][]byte, error) {
+	return w.db.List(ctx, prefix)
+}
+
+// Delete clears a set of keys from the db.
+// Delete events are emitted for every key, whether or not it existed.
+func (w *WatchedDb) Delete(ctx context.Context, keys ...[]byte) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if err := w.db.Delete(ctx, keys...); err != nil {
+		return err
+	}
+
+	w.emitDelete(keys...)
+	return nil
+}
+
+// DropPrefix deletes all keys with a prefix.
+// The keys are listed and deleted a page at a time to emit delete events.
+func (w *WatchedDb) DropPrefix(ctx context.Context, prefix []byte) error {
+	return db.ForEachPage(ctx, w.db, prefix, db.DefaultPageSize, func(keys [][]byte) error {
+		return w.Delete(ctx, keys...)
+	})
+}
+
+// CompareAndSwap sets the key to val if the current value equals old.
+func (w *WatchedDb) CompareAndSwap(ctx context.Context, key []byte, old, val []byte) (bool, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	swapped, err := db.CompareAndSwap(ctx, w.db, key, old, val)
+	if err == nil && swapped {
+		w.emitPut(key, val)
+	}
+	return swapped, err
+}
+
+// SetIfNotExists sets the key to val if the key does not exist.
+func (w *WatchedDb) SetIfNotExists(ctx context.Context, key []byte, val []byte) (bool, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	set, err := db.SetIfNotExists(ctx, w.db, key, val)
+	if err == nil && set {
+		w.emitPut(key, val)
+	}
+	return set, err
+}
+
+// NewIterator builds a new iterator over the underlying db.
+func (w *WatchedDb) NewIterator(ctx context.Context, opts db.IteratorOpts) (db.Iterator, error) {
+	return db.NewIterator(ctx, w.db, opts)
+}
+
+// NewTxn builds a new transaction against the underlying db.
+// Events for the writes in the transaction are emitted after it commits.
+func (w *WatchedDb) NewTxn(ctx context.Context, write bool) (db.Txn, error) {
+	txn, err := db.NewTxn(ctx, w.db, write)
+	if err != nil {
+		return nil, err
+	}
+
+	return &watchedTxn{Txn: txn, w: w}, nil
+}
+
+// emitPut emits a put event.
+func (w *WatchedDb) emitPut(key, val []byte) {
+	w.watch.Emit(db.Event{
+		Type:  db.EventPut,
+		Key:   copyBytes(key),
+		Value: copyBytes(val),
+	})
+}
+
+// emitDelete emits delete events.
+func (w *WatchedDb) emitDelete(keys ...[]byte) {
+	events := make([]db.Event, len(keys))
+	for i, key := range keys {
+		events[i] = db.Event{Type: db.EventDelete, Key: copyBytes(key)}
+	}
+	w.watch.Emit(events...)
+}
+
+// watchedTxn records the writes in a txn.
+type watchedTxn struct {
+	db.Txn
+	w      *WatchedDb
+	events []db.Event
+}
+
+// Set sets an object in the transaction.
+func (t *watchedTxn) Set(ctx context.Context, key []byte, val []byte) error {
+	if err := t.Txn.Set(ctx, key, val); err != nil {
+		return err
+	}
+
+	t.events = append(t.events, db.Event{
+		Type:  db.EventPut,
+		Key:   copyBytes(key),
+		Value: copyBytes(val),
+	})
+	return nil
+}
+
+// Delete clears a set of keys in the transaction.
+func (t *watchedTxn) Delete(ctx context.Context, keys ...[]byte) error {
+	if err := t.Txn.Delete(ctx, keys...); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		t.events = append(t.events, db.Event{Type: db.EventDelete, Key: copyBytes(key)})
+	}
+	return nil
+}
+
+// Commit commits the transaction, emitting the events if successful.
+func (t *watchedTxn) Commit(ctx context.Context) error {
+	t.w.mtx.Lock()
+	defer t.w.mtx.Unlock()
+
+	if err := t.Txn.Commit(ctx); err != nil {
+		return err
+	}
+
+	events := t.events
+	t.events = nil
+	t.w.watch.Emit(events...)
+	return nil
+}
+
+// copyBytes copies a byte slice.
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// Close closes the underlying db.
+func (w *WatchedDb) Close() error {
+	return db.Close(w.db)
+}
+
+// _ are type assertions
+var (
+	_ db.Db                = &WatchedDb{}
+	_ db.Batcher           = &WatchedDb{}
+	_ db.Closer            = &WatchedDb{}
+	_ db.CompareAndSwapper = &WatchedDb{}
+	_ db.Iterable          = &WatchedDb{}
+	_ db.PrefixDropper     = &WatchedDb{}
+	_ db.TTLGetter         = &WatchedDb{}
+	_ db.TTLSetter         = &WatchedDb{}
+	_ db.Watcher           = &WatchedDb{}
+)
@@ -0,0 +1,132 @@
+package watched
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/dbtest"
+	"github.com/aperturerobotics/objstore/db/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance runs the db conformance suite.
+func TestConformance(t *testing.T) {
+	dbtest.RunConformance(t, func() db.Db {
+		return NewWatchedDb(inmem.NewInmemDb())
+	})
+}
+
+// TestWatchTxn tests events are emitted when a txn commits.
+func TestWatchTxn(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	w := NewWatchedDb(inmem.NewInmemDb())
+	ch, err := w.Watch(ctx, nil)
+	require.NoError(t, err)
+
+	txn, err := w.NewTxn(ctx, true)
+	require.NoError(t, err)
+	require.NoError(t, txn.Set(ctx, []byte("/a"), []byte("a")))
+	require.NoError(t, txn.Delete(ctx, []byte("/b")))
+	require.Len(t, ch, 0)
+	require.NoError(t, txn.Commit(ctx))
+	txn.Discard()
+
+	ev := <-ch
+	require.Equal(t, db.EventPut, ev.Type)
+	require.Equal(t, []byte("/a"), ev.Key)
+	ev = <-ch
+	require.Equal(t, db.EventDelete, ev.Type)
+	require.Equal(t, []byte("/b"), ev.Key)
+}
+
+// TestWatchOverflow tests a watcher which falls behind is sent an overflow.
+func TestWatchOverflow(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	w := NewWatchedDb(inmem.NewInmemDb())
+	ch, err := w.Watch(ctx, nil)
+	require.NoError(t, err)
+
+	for i := 0; i <= db.WatchBufferSize; i++ {
+		require.NoError(t, w.Set(ctx, []byte(fmt.Sprintf("/%d", i)), nil))
+	}
+
+	for i := 0; i < db.WatchBufferSize; i++ {
+		ev := <-ch
+		require.Equal(t, db.EventPut, ev.Type)
+	}
+	ev, ok := <-ch
+	require.True(t, ok)
+	require.Equal(t, db.EventOverflow, ev.Type)
+	_, ok = <-ch
+	require.False(t, ok)
+}
+
+// TestDropPrefix tests dropping more than a page of keys emits deletes for
+// every page.
+func TestDropPrefix(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	w := NewWatchedDb(inmem.NewInmemDb())
+	for i := 0; i <= db.DefaultPageSize; i++ {
+		require.NoError(t, w.Set(ctx, []byte(fmt.Sprintf("/a/%d", i)), nil))
+	}
+	require.NoError(t, w.Set(ctx, []byte("/b"), nil))
+
+	// the last key sorts into the second page
+	last := []byte(fmt.Sprintf("/a/%d", db.DefaultPageSize-1))
+	ch, err := w.Watch(ctx, last)
+	require.NoError(t, err)
+
+	require.NoError(t, w.DropPrefix(ctx, []byte("/a/")))
+	require.Len(t, ch, 1)
+	ev := <-ch
+	require.Equal(t, db.EventDelete, ev.Type)
+	require.Equal(t, last, ev.Key)
+
+	keys, err := w.List(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("/b")}, keys)
+}
+
+// TestConcurrentWrites tests the last event for a key written concurrently
+// matches the stored value.
+func TestConcurrentWrites(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	w := NewWatchedDb(inmem.NewInmemDb())
+	ch, err := w.Watch(ctx, []byte("/a"))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				val := []byte(fmt.Sprintf("%d-%d", i, j))
+				if err := w.Set(ctx, []byte("/a"), val); err != nil {
+					t.Error(err.Error())
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var last db.Event
+	for i := 0; i < 200; i++ {
+		last = <-ch
+	}
+	val, _, err := w.Get(ctx, []byte("/a"))
+	require.NoError(t, err)
+	require.Equal(t, val, last.Value)
+}
@@ -2,6 +2,7 @@ package btree
 
 import (
 	"context"
+	"errors"
 	"sort"
 	"sync"
 
@@ -14,6 +15,9 @@ import (
 // maxNodeChildren is the maximum number children nodes of a node.
 const maxNodeChildren = 16
 
+// errMissingChild is returned when a child reference of a node is empty.
+var errMissingChild = errors.New("btree: missing child node reference")
+
 // BTree is an implementation of a objstore backed BTree.
 // The key is a string, and the value is a storageref.
 type BTree struct {
@@ -22,46 +26,54 @@ type BTree struct {
 	objStore   *objstore.ObjectStore
 	rootNodRef *storageref.StorageRef
 	rootNod    *Root
-	root       *memNode
-	encConf    pbobject.EncryptionConfig
+	// root is the root node in memory, nil if it must be loaded from rootNod.
+	root    *memNode
+	encConf pbobject.EncryptionConfig
 
-	opCtx    *operationCtx
-	freeList sync.Pool
+	opCtx *operationCtx
+	// nextID is the last memory node ID assigned.
+	nextID uint32
 }
 
-// NewBTree builds a new btree, writing state to the db.
+// NewBTree builds a new btree, writing state to the db in a transaction.
 // Any errors writing initial state will be returned.
 func NewBTree(
 	ctx context.Context,
 	objStore *objstore.ObjectStore,
 	encConf pbobject.EncryptionConfig,
 ) (*BTree, error) {
-	rootNode := &Node{}
-	rootNode.Leaf = true
-	rootRef, _, err := objStore.StoreObject(ctx, rootNode, encConf)
+	txStore, err := objStore.NewTxn(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer txStore.Discard()
 
-	bt := &BTree{
-		objStore: objStore,
-		encConf:  encConf,
-		freeList: sync.Pool{New: func() interface{} { return &Node{} }},
+	rootNode := &Node{}
+	rootNode.Leaf = true
+	rootRef, _, err := txStore.StoreObject(ctx, rootNode, encConf)
+	if err != nil {
+		return nil, err
 	}
 
-	rootMemNod := bt.newNode()
-	rootMemNod.node = rootNode
-	bt.root = rootMemNod
-
 	rootNod := &Root{
 		RootNodeRef: rootRef,
 	}
-	rootNodRef, _, err := objStore.StoreObject(ctx, rootNod, encConf)
+	rootNodRef, _, err := txStore.StoreObject(ctx, rootNod, encConf)
 	if err != nil {
 		return nil, err
 	}
-	bt.rootNod = rootNod
-	bt.rootNodRef = rootNodRef
+
+	if err := txStore.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	bt := &BTree{
+		objStore:   objStore,
+		encConf:    encConf,
+		rootNod:    rootNod,
+		rootNodRef: rootNodRef,
+	}
+	bt.root = bt.newMemNode(rootNode)
 
 	return bt, nil
 }
@@ -84,10 +96,9 @@ func LoadBTree(
 		rootNod:    rootNod,
 		rootNodRef: rootRef,
 		encConf:    encConf,
-		freeList:   sync.Pool{New: func() interface{} { return &Node{} }},
 	}
 
-	rootMemNode, err := bt.followNodeRef(rootNod.GetRootNodeRef())
+	rootMemNode, err := bt.followNodeRef(ctx, rootNod.GetRootNodeRef())
 	if err != nil {
 		return nil, err
 	}
@@ -104,27 +115,56 @@ func (b *BTree) Len() int {
 	return int(b.rootNod.GetLength())
 }
 
-// newNode builds a new node.
+// newMemNode wraps a node in a new memory node.
+func (b *BTree) newMemNode(n *Node) *memNode {
+	b.nextID++
+	return &memNode{id: b.nextID, node: n}
+}
+
+// newNode builds a new node, marking it dirty in the current operation.
 func (b *BTree) newNode() *memNode {
-	fget := b.freeList.Get()
-	var n *Node
-	if fget != nil {
-		n = fget.(*Node)
-		n.Reset()
-	} else {
-		n = &Node{}
+	mn := b.newMemNode(&Node{})
+	b.opCtx.PushDirtyNode(mn)
+	return mn
+}
+
+// beginOp starts an operation, loading the root node if necessary.
+// Expects mtx to be locked.
+func (b *BTree) beginOp(ctx context.Context) error {
+	if b.root == nil {
+		root, err := b.followNodeRef(ctx, b.rootNod.GetRootNodeRef())
+		if err != nil {
+			return err
+		}
+		b.root = root
 	}
 
-	// var parentIdx int
-	var depth int // parent == nil -> depth=0 root
+	rootNod := &Root{
+		RootNodeRef: b.rootNod.GetRootNodeRef(),
+		Length:      b.rootNod.GetLength(),
+	}
+	b.opCtx = newOperationCtx(ctx, rootNod, b.root)
+	return nil
+}
 
-	mn := &memNode{
-		id:    b.opCtx.GetNextID(),
-		depth: depth,
-		node:  n,
+// endOp flushes the changes made by the operation if it succeeded.
+// If the operation or the flush fails, the nodes in memory are dropped, and
+// are loaded from the last written root on the next operation.
+// Expects mtx to be locked.
+func (b *BTree) endOp(rerr *error) {
+	opCtx := b.opCtx
+	b.opCtx = nil
+	if *rerr == nil {
+		rootNodRef, err := opCtx.Flush(b.objStore, b.encConf)
+		if err == nil {
+			b.rootNod = opCtx.rootNod
+			b.rootNodRef = rootNodRef
+			return
+		}
+		*rerr = err
 	}
-	b.opCtx.PushDirtyNode(mn)
-	return mn
+
+	b.root = nil
 }
 
 // GetRootNodeRef returns the reference to the root node.
@@ -145,13 +185,20 @@ func (b *BTree) ReplaceOrInsert(
 		return nil, nil
 	}
 
-	item := &Item{Key: key, Ref: val}
-	b.opCtx = newOperationCtx(ctx, &b.mtx, b.rootNod, b.root, &b.rootNodRef)
-	defer b.opCtx.Flush(b.objStore, b.encConf, &b.freeList, &rerr)
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
 
-	if b.rootNod.Length == 0 {
+	if err := b.beginOp(ctx); err != nil {
+		return nil, err
+	}
+	defer b.endOp(&rerr)
+
+	item := &Item{Key: key, Ref: val}
+	rootNod := b.opCtx.rootNod
+	if rootNod.Length == 0 {
 		b.root.node.Items = append(b.root.node.Items, item)
-		b.rootNod.Length++
+		b.opCtx.PushDirtyNode(b.root)
+		rootNod.Length++
 		return nil, nil
 	}
 
@@ -162,6 +209,7 @@ func (b *BTree) ReplaceOrInsert(
 		b.root = b.newNode()
 		b.root.node.Items = append(b.root.node.Items, i2)
 		b.root.node.ChildrenRefs = []*storageref.StorageRef{nil, nil}
+		b.opCtx.root = b.root
 
 		oldRoot.setParent(b.root, 0)
 		s.setParent(b.root, 1)
@@ -175,7 +223,7 @@ func (b *BTree) ReplaceOrInsert(
 	}
 
 	if out == nil {
-		b.rootNod.Length++
+		rootNod.Length++
 	}
 
 	return out.GetRef(), nil
@@ -255,7 +303,15 @@ func (b *BTree) splitNode(n *memNode, i int) (*Item, *memNode) {
 
 	if len(n.node.ChildrenRefs) > 0 {
 		next.node.ChildrenRefs = append(next.node.ChildrenRefs, n.node.ChildrenRefs[i+1:]...)
-		n.node.ChildrenRefs = n.node.ChildrenRefs[:i]
+		n.node.ChildrenRefs = n.node.ChildrenRefs[:i+1]
+	}
+
+	// move the loaded children after the split to the new node
+	for ci, c := range n.loadedChildren {
+		if ci > i {
+			delete(n.loadedChildren, ci)
+			c.setParent(next, ci-i-1)
+		}
 	}
 
 	return item, next
@@ -328,23 +384,26 @@ func (b *BTree) followChildRef(n *memNode, i int) (*memNode, error) {
 		}
 	}
 
-	mn, err := b.followNodeRef(n.node.ChildrenRefs[i])
+	mn, err := b.followNodeRef(b.opCtx.ctx, n.node.ChildrenRefs[i])
 	if err != nil {
 		return nil, err
 	}
+	if mn == nil {
+		return nil, errMissingChild
+	}
 
 	mn.setParent(n, i)
 	return mn, nil
 }
 
 // followNodeRef loads a node reference into memory.
-func (b *BTree) followNodeRef(ref *storageref.StorageRef) (*memNode, error) {
+func (b *BTree) followNodeRef(ctx context.Context, ref *storageref.StorageRef) (*memNode, error) {
 	if ref.IsEmpty() {
 		return nil, nil
 	}
 
-	ctx := objstore.WithObjStore(b.opCtx.ctx, b.objStore)
-	n := b.newNode()
+	ctx = objstore.WithObjStore(ctx, b.objStore)
+	n := b.newMemNode(&Node{})
 	if err := ref.FollowRef(ctx, nil, n.node, nil); err != nil {
 		return nil, err
 	}
@@ -369,6 +428,14 @@ func (m *memNode) assertLoadedChildren() {
 	}
 }
 
+// updateDepth sets the depth of the memnode from its number of ancestors.
+func (m *memNode) updateDepth() {
+	m.depth = 0
+	for p := m.parent; p != nil; p = p.parent {
+		m.depth++
+	}
+}
+
 // setParent links the memnode to its parent.
 func (m *memNode) setParent(parent *memNode, i int) {
 	parent.assertLoadedChildren()
@@ -383,10 +450,11 @@ func (m *memNode) setParent(parent *memNode, i int) {
 // is in ascending order, this should return > logic.
 // Return 1 to indicate this object is greater than the
 // the other logic, 0 to indicate equality, and -1 to indicate
-// less than other.
+// less than other. Deeper nodes sort first, so they are flushed before their
+// parents.
 func (n *memNode) Compare(other queue.Item) int {
 	on := other.(*memNode)
-	depthCmp := n.depth - on.depth
+	depthCmp := on.depth - n.depth
 	if depthCmp == 0 {
 		return int(n.id) - int(on.id)
 	}
@@ -2,14 +2,19 @@ package btree
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"sort"
 	"testing"
 
 	"github.com/aperturerobotics/objstore"
+	"github.com/aperturerobotics/objstore/db/faulty"
 	"github.com/aperturerobotics/objstore/db/inmem"
 	"github.com/aperturerobotics/objstore/localdb"
 	"github.com/aperturerobotics/pbobject"
 	"github.com/aperturerobotics/storageref"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSimple(t *testing.T) {
@@ -21,7 +26,7 @@ func TestSimple(t *testing.T) {
 	assert.NoError(t, err)
 
 	key := "test"
-	val := ((*storageref.StorageRef)(nil))
+	val := &storageref.StorageRef{}
 	iv, err := bt.ReplaceOrInsert(ctx, key, val)
 	assert.NoError(t, err)
 	assert.Nil(t, iv)
@@ -30,3 +35,135 @@ func TestSimple(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, iv)
 }
+
+// testRef builds a value reference for a key.
+func testRef(key string) *storageref.StorageRef {
+	return &storageref.StorageRef{ObjectDigest: []byte(key)}
+}
+
+// walkKeys lists the keys stored under a node reference in order.
+func walkKeys(t *testing.T, ctx context.Context, bt *BTree, ref *storageref.StorageRef) []string {
+	n, err := bt.followNodeRef(ctx, ref)
+	require.NoError(t, err)
+	require.NotNil(t, n)
+
+	var keys []string
+	children := n.node.GetChildrenRefs()
+	if len(children) == 0 {
+		for _, item := range n.node.GetItems() {
+			keys = append(keys, item.GetKey())
+		}
+		return keys
+	}
+
+	require.Len(t, children, len(n.node.GetItems())+1)
+	for i, item := range n.node.GetItems() {
+		keys = append(keys, walkKeys(t, ctx, bt, children[i])...)
+		keys = append(keys, item.GetKey())
+	}
+	return append(keys, walkKeys(t, ctx, bt, children[len(children)-1])...)
+}
+
+// checkTree loads the tree at the root reference and checks it contains keys.
+func checkTree(
+	t *testing.T,
+	ctx context.Context,
+	objStore *objstore.ObjectStore,
+	rootRef *storageref.StorageRef,
+	keys []string,
+) {
+	bt, err := LoadBTree(ctx, objStore, pbobject.EncryptionConfig{}, rootRef)
+	require.NoError(t, err)
+	require.Equal(t, len(keys), bt.Len())
+
+	expected := append([]string(nil), keys...)
+	sort.Strings(expected)
+	actual := walkKeys(t, ctx, bt, bt.rootNod.GetRootNodeRef())
+	require.Equal(t, expected, actual)
+}
+
+// TestInsertMany tests inserting enough keys to split the root several times.
+func TestInsertMany(t *testing.T) {
+	ctx := context.Background()
+	objStore := objstore.NewObjectStore(ctx, localdb.NewLocalDb(inmem.NewInmemDb()), nil)
+	bt, err := NewBTree(ctx, objStore, pbobject.EncryptionConfig{})
+	require.NoError(t, err)
+
+	keys := make([]string, 200)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%03d", i)
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+
+	for _, key := range keys {
+		old, err := bt.ReplaceOrInsert(ctx, key, testRef(key))
+		require.NoError(t, err)
+		require.Nil(t, old)
+	}
+	require.Equal(t, len(keys), bt.Len())
+	checkTree(t, ctx, objStore, bt.GetRootNodeRef(), keys)
+
+	// replacing returns the old value and keeps the length
+	old, err := bt.ReplaceOrInsert(ctx, keys[0], testRef("new"))
+	require.NoError(t, err)
+	require.Equal(t, testRef(keys[0]).GetObjectDigest(), old.GetObjectDigest())
+	require.Equal(t, len(keys), bt.Len())
+	checkTree(t, ctx, objStore, bt.GetRootNodeRef(), keys)
+}
+
+// TestFaultRecovery tests the tree recovers from failed reads and writes.
+func TestFaultRecovery(t *testing.T) {
+	ctx := context.Background()
+	f := faulty.NewFaultyDb(inmem.NewInmemDb(), faulty.Config{})
+	objStore := objstore.NewObjectStore(ctx, localdb.NewLocalDb(f), nil)
+	bt, err := NewBTree(ctx, objStore, pbobject.EncryptionConfig{})
+	require.NoError(t, err)
+
+	var keys []string
+	insert := func(key string) error {
+		_, err := bt.ReplaceOrInsert(ctx, key, testRef(key))
+		if err == nil {
+			keys = append(keys, key)
+		}
+		return err
+	}
+	for i := 0; i < 50; i++ {
+		require.NoError(t, insert(fmt.Sprintf("key-%03d", i*2)))
+	}
+
+	// a failed commit leaves the tree unchanged
+	rootRef := bt.GetRootNodeRef()
+	f.AddRule(faulty.Rule{Ops: faulty.OpCommit, Times: 1})
+	require.Equal(t, faulty.ErrInjected, insert("key-051"))
+	require.Equal(t, len(keys), bt.Len())
+	require.Equal(t, rootRef, bt.GetRootNodeRef())
+	checkTree(t, ctx, objStore, rootRef, keys)
+
+	// the nodes are reloaded after a failure, a failed read fails the operation
+	f.ClearRules()
+	f.AddRule(faulty.Rule{Ops: faulty.OpGet, Times: 1})
+	require.Equal(t, faulty.ErrInjected, insert("key-051"))
+	require.Equal(t, rootRef, bt.GetRootNodeRef())
+
+	// the tree recovers once the faults are cleared
+	f.ClearRules()
+	require.NoError(t, insert("key-051"))
+	for i := 0; i < 25; i++ {
+		require.NoError(t, insert(fmt.Sprintf("key-%03d", i*2+101)))
+	}
+	require.Equal(t, len(keys), bt.Len())
+	checkTree(t, ctx, objStore, bt.GetRootNodeRef(), keys)
+
+	// writes discarded by a crash are not visible from the last written root
+	f.ClearRules()
+	rootRef = bt.GetRootNodeRef()
+	committed := append([]string(nil), keys...)
+	f.CrashAfter(0)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, insert(fmt.Sprintf("key-%03d", i*2+1)))
+	}
+	require.True(t, f.Crashed())
+	checkTree(t, ctx, objStore, rootRef, committed)
+}
@@ -2,8 +2,7 @@ package btree
 
 import (
 	"context"
-	"sync"
-	"sync/atomic"
+	"errors"
 
 	"github.com/Workiva/go-datastructures/queue"
 	"github.com/aperturerobotics/objstore"
@@ -11,54 +10,36 @@ import (
 	"github.com/aperturerobotics/storageref"
 )
 
+// errDetachedNode is returned when flushing a dirty node which is not linked
+// to the tree.
+var errDetachedNode = errors.New("btree: dirty node is not linked to the tree")
+
 // operationCtx tracks dirty nodes and manages flushing them to the db.
 type operationCtx struct {
-	ctx        context.Context
-	mtx        *sync.Mutex
-	flushOnce  sync.Once
-	dirtyQueue *queue.PriorityQueue
-	memNodeID  uint32
-	rootNod    *Root
-	root       *memNode
-	rootNodRef **storageref.StorageRef
+	ctx context.Context
+	// dirty is the set of nodes changed by the operation.
+	dirty map[*memNode]struct{}
+	// rootNod is the root object written by the operation.
+	rootNod *Root
+	// root is the root node, updated if the root is split.
+	root *memNode
 }
 
 // newOperationCtx sets up a new operation context.
-func newOperationCtx(
-	ctx context.Context,
-	opMtx *sync.Mutex,
-	rootNod *Root,
-	root *memNode,
-	rootNodRef **storageref.StorageRef,
-) *operationCtx {
-	if opMtx != nil {
-		opMtx.Lock()
-	}
-
+func newOperationCtx(ctx context.Context, rootNod *Root, root *memNode) *operationCtx {
 	return &operationCtx{
-		ctx:        ctx,
-		root:       root,
-		mtx:        opMtx,
-		dirtyQueue: queue.NewPriorityQueue(0, false),
-		rootNod:    rootNod,
-		rootNodRef: rootNodRef,
+		ctx:     ctx,
+		dirty:   make(map[*memNode]struct{}),
+		rootNod: rootNod,
+		root:    root,
 	}
 }
 
-// GetNextID returns the next memory node ID.
-func (o *operationCtx) GetNextID() uint32 {
-	return atomic.AddUint32(&o.memNodeID, 1)
-}
-
-// PushDirtyNode pushes a dirty node to the queue.
-func (o *operationCtx) PushDirtyNode(n ...*memNode) error {
+// PushDirtyNode marks nodes as changed by the operation.
+func (o *operationCtx) PushDirtyNode(n ...*memNode) {
 	for _, ni := range n {
-		if err := o.dirtyQueue.Put(ni); err != nil {
-			return err
-		}
+		o.dirty[ni] = struct{}{}
 	}
-
-	return nil
 }
 
 // GetContext returns the context.
@@ -66,81 +47,75 @@ func (o *operationCtx) GetContext() context.Context {
 	return o.ctx
 }
 
-// Flush flushes the operation context.
+// Flush writes the dirty nodes, the root node and the root object to the local
+// store in a transaction. Nodes are written deepest first, updating the child
+// reference in the parent, so every parent is written after its children.
+// Returns the reference to the new root object.
 func (o *operationCtx) Flush(
 	objStore *objstore.ObjectStore,
 	encConf pbobject.EncryptionConfig,
-	freeList *sync.Pool,
-	outErr *error,
-) (retErr error) {
-	if outErr != nil {
-		if e := *outErr; e != nil {
-			return e
+) (*storageref.StorageRef, error) {
+	ctx := o.ctx
+	txStore, err := objStore.NewTxn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer txStore.Discard()
+
+	// depths are only final once the operation is complete
+	dirtyQueue := queue.NewPriorityQueue(len(o.dirty), false)
+	defer dirtyQueue.Dispose()
+	for mn := range o.dirty {
+		if mn == o.root {
+			continue
 		}
 
-		defer func() {
-			*outErr = retErr
-		}()
+		mn.updateDepth()
+		if err := dirtyQueue.Put(mn); err != nil {
+			return nil, err
+		}
 	}
 
-	ctx := o.ctx
-	defer o.dirtyQueue.Dispose()
-	defer o.mtx.Unlock()
-
-	for {
-		vals, _ := o.dirtyQueue.Get(10)
-		if len(vals) == 0 {
-			break
+	for !dirtyQueue.Empty() {
+		vals, err := dirtyQueue.Get(1)
+		if err != nil {
+			return nil, err
 		}
 
-		for _, v := range vals {
-			mn := v.(*memNode)
-
-			// sweep nil refs
-			for i := 0; i < len(mn.node.ChildrenRefs); i++ {
-				ref := mn.node.ChildrenRefs[i]
-				if ref == nil {
-					mn.node.ChildrenRefs[i] = mn.node.ChildrenRefs[len(mn.node.ChildrenRefs)-1]
-					mn.node.ChildrenRefs[len(mn.node.ChildrenRefs)-1] = nil
-					mn.node.ChildrenRefs = mn.node.ChildrenRefs[:len(mn.node.ChildrenRefs)-1]
-					i--
-				}
-			}
-
-			nodRef, _, err := objStore.StoreObject(ctx, mn.node, encConf)
-			if err != nil {
-				return err
-			}
-
-			if mn.parent == nil {
-				// root node
-				continue
-			}
+		mn := vals[0].(*memNode)
+		parent := mn.parent
+		if parent == nil || mn.parentIdx >= len(parent.node.ChildrenRefs) {
+			return nil, errDetachedNode
+		}
 
-			pn := mn.parent.node
-			pn.ChildrenRefs[mn.parentIdx] = nodRef
-			if err := o.PushDirtyNode(mn.parent); err != nil {
-				return err
-			}
+		nodRef, _, err := txStore.StoreObject(ctx, mn.node, encConf)
+		if err != nil {
+			return nil, err
+		}
 
-			if freeList != nil {
-				freeList.Put(mn.node)
+		parent.node.ChildrenRefs[mn.parentIdx] = nodRef
+		if parent != o.root {
+			parent.updateDepth()
+			if err := dirtyQueue.Put(parent); err != nil {
+				return nil, err
 			}
 		}
 	}
 
-	rootRef, _, err := objStore.StoreObject(ctx, o.root.node, encConf)
+	rootRef, _, err := txStore.StoreObject(ctx, o.root.node, encConf)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
 	o.rootNod.RootNodeRef = rootRef
 
-	rootNodRef, _, err := objStore.StoreObject(ctx, o.rootNod, encConf)
+	rootNodRef, _, err := txStore.StoreObject(ctx, o.rootNod, encConf)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := txStore.Commit(ctx); err != nil {
+		return nil, err
 	}
 
-	*o.rootNodRef = rootNodRef
-	return nil
+	return rootNodRef, nil
 }
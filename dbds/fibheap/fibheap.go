@@ -15,6 +15,7 @@ type FibbonaciHeap struct {
 	db         db.Db
 	keyDb      db.Db
 	root       Root
+	rootDirty  bool
 	entryCache map[string]*Entry
 }
 
@@ -188,20 +189,20 @@ func (h *FibbonaciHeap) Merge(ctx context.Context, other *FibbonaciHeap) (rerr e
 	other.mtx.Lock()
 	defer other.mtx.Unlock()
 	defer func() {
+		// discard the changes to other, the moved entries now belong to h
 		other.entryCache = nil
+		other.rootDirty = false
+		if err := other.readState(ctx); err != nil && rerr == nil {
+			rerr = err
+		}
 	}()
 
 	resultSize := h.root.Size
 
 	// unfortunately, have to remove any keys in other that exist in h.
 	// this is to avoid collisions
-	otherKeys, err := other.keyDb.List(ctx, nil)
-	if err != nil {
-		return err
-	}
-
 	// remove any keys that would collide
-	for _, key := range otherKeys {
+	err := db.ForEachKey(ctx, other.keyDb, nil, func(key []byte) error {
 		id := string(key[1:])
 		otherEntry, err := other.getEntry(ctx, id, false)
 		if err != nil {
@@ -218,13 +219,15 @@ func (h *FibbonaciHeap) Merge(ctx context.Context, other *FibbonaciHeap) (rerr e
 		}
 
 		if hvOk {
-			if err := other.dequeueKeyByID(ctx, id, otherEntry); err != nil {
-				return err
-			}
-		} else {
-			h.entryCache[id] = otherEntry
-			resultSize++
+			return other.dequeueKeyByID(ctx, id, otherEntry)
 		}
+
+		h.entryCache[id] = otherEntry
+		resultSize++
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	heapMin, err := h.getEntry(ctx, h.root.Min, false)
@@ -331,11 +334,8 @@ func (h *FibbonaciHeap) dequeueMinEntry(ctx context.Context) (*Entry, string, er
 		return nil, "", err
 	}
 
-	delete(h.entryCache, minID)
-	minIDKey := h.getIDKey(minID)
-	if err := h.keyDb.Delete(ctx, minIDKey); err != nil {
-		return nil, "", err
-	}
+	// mark the entry for deletion
+	h.entryCache[minID] = nil
 
 	if nmine == nil {
 		return min, minID, nil
@@ -3,6 +3,7 @@ package fibheap
 import (
 	"context"
 
+	"github.com/aperturerobotics/objstore/db"
 	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
 )
@@ -20,20 +21,17 @@ func (h *FibbonaciHeap) readState(ctx context.Context) error {
 	}
 
 	if !dOk {
+		h.root.Reset()
 		return h.writeState(ctx)
 	}
 
 	return proto.Unmarshal(d, &h.root)
 }
 
-// writeState writes state to the db.
+// writeState marks the state to be written to the db with the entry cache.
 func (h *FibbonaciHeap) writeState(ctx context.Context) error {
-	d, err := proto.Marshal(&h.root)
-	if err != nil {
-		return err
-	}
-
-	return h.db.Set(ctx, fibRootKey, d)
+	h.rootDirty = true
+	return nil
 }
 
 // getIDKey returns the key for the given ID.
@@ -41,6 +39,11 @@ func (h *FibbonaciHeap) getIDKey(id string) []byte {
 	return append([]byte{'/'}, []byte(id)...)
 }
 
+// getEntryKey returns the key in the db for the given ID.
+func (h *FibbonaciHeap) getEntryKey(id string) []byte {
+	return append(append([]byte(nil), fibKeyPrefix...), h.getIDKey(id)...)
+}
+
 // getEntry gets the entry with the specified ID from the db.
 func (h *FibbonaciHeap) getEntry(ctx context.Context, id string, alloc bool) (*Entry, error) {
 	if id == "" {
@@ -77,16 +80,20 @@ func (h *FibbonaciHeap) getEntry(ctx context.Context, id string, alloc bool) (*E
 	return entry, nil
 }
 
-// setEntry sets the entry with the specified ID to the db.
-func (h *FibbonaciHeap) setEntry(ctx context.Context, id string, entry *Entry) error {
-	idKey := h.getIDKey(id)
+// setEntry sets the entry with the specified ID in the transaction.
+// A nil entry is deleted.
+func (h *FibbonaciHeap) setEntry(ctx context.Context, txn db.Txn, id string, entry *Entry) error {
+	entryKey := h.getEntryKey(id)
+	if entry == nil {
+		return txn.Delete(ctx, entryKey)
+	}
 
 	dat, err := proto.Marshal(entry)
 	if err != nil {
 		return err
 	}
 
-	return h.keyDb.Set(ctx, idKey, dat)
+	return txn.Set(ctx, entryKey, dat)
 }
 
 // editEntry gets an entry, edits it, then writes it back.
@@ -114,6 +121,10 @@ func (h *FibbonaciHeap) editEntry(ctx context.Context, id string, cb func(e *Ent
 }
 
 // flushEntryCache writes the contents of the entry cache and clears it.
+// The entries and the state are written in a single transaction, so an
+// operation is applied atomically if the db supports transactions.
+// If the operation or the write fails, the changes are discarded and the state
+// is reloaded from the db.
 func (h *FibbonaciHeap) flushEntryCache(ctx context.Context, rerrp *error) (rerr error) {
 	defer func() {
 		if rerrp != nil && rerr != nil {
@@ -122,32 +133,69 @@ func (h *FibbonaciHeap) flushEntryCache(ctx context.Context, rerrp *error) (rerr
 	}()
 
 	if rerrp != nil && *rerrp != nil {
-		// don't save entry changes, due to error
-		h.entryCache = nil
+		// don't save changes, due to error
+		return h.discardChanges(ctx)
 	}
-	if h.entryCache == nil {
-		h.entryCache = make(map[string]*Entry)
-		return
+
+	if err := h.writeChanges(); err != nil {
+		if derr := h.discardChanges(ctx); derr != nil {
+			return derr
+		}
+		return err
 	}
 
-	if len(h.entryCache) == 0 {
-		return
+	return nil
+}
+
+// writeChanges writes the entry cache and state in a transaction.
+func (h *FibbonaciHeap) writeChanges() error {
+	if len(h.entryCache) == 0 && !h.rootDirty {
+		h.entryCache = make(map[string]*Entry)
+		return nil
 	}
 
 	// use a temporary sub-context
 	// may break if Value() is used anywhere
 	tmpCtx := context.Background()
+	txn, err := db.NewTxn(tmpCtx, h.db, true)
+	if err != nil {
+		return err
+	}
+	defer txn.Discard()
+
 	for k, e := range h.entryCache {
-		rerr = h.setEntry(tmpCtx, k, e)
-		if rerr != nil {
-			break
+		if err := h.setEntry(tmpCtx, txn, k, e); err != nil {
+			return err
 		}
-		delete(h.entryCache, k)
 	}
 
+	if h.rootDirty {
+		d, err := proto.Marshal(&h.root)
+		if err != nil {
+			return err
+		}
+
+		if err := txn.Set(tmpCtx, fibRootKey, d); err != nil {
+			return err
+		}
+	}
+
+	if err := txn.Commit(tmpCtx); err != nil {
+		return err
+	}
+
+	h.entryCache = make(map[string]*Entry)
+	h.rootDirty = false
 	return nil
 }
 
+// discardChanges clears the entry cache and reloads the state from the db.
+func (h *FibbonaciHeap) discardChanges(ctx context.Context) error {
+	h.entryCache = make(map[string]*Entry)
+	h.rootDirty = false
+	return h.readState(ctx)
+}
+
 // getPrevNext returns the previous and next entries for an entry.
 func (h *FibbonaciHeap) getPrevNext(
 	ctx context.Context,
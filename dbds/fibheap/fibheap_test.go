@@ -4,11 +4,15 @@ package fibheap
 
 import (
 	"context"
+	"math"
+	"regexp"
 	"strconv"
 	"testing"
 
 	"math/rand"
 
+	"github.com/aperturerobotics/objstore/db"
+	"github.com/aperturerobotics/objstore/db/faulty"
 	"github.com/aperturerobotics/objstore/db/inmem"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -334,6 +338,101 @@ func TestMerge(t *testing.T) {
 	}
 }
 
+// requireConsistent reopens the heap in d and checks it dequeues size elements
+// in priority order.
+func requireConsistent(t *testing.T, d db.Db, size int) {
+	ctx := context.Background()
+	heap, err := NewFibbonaciHeap(ctx, d)
+	require.NoError(t, err)
+	require.Equal(t, size, heap.Size())
+
+	prev := math.Inf(-1)
+	for i := 0; i < size; i++ {
+		key, pmin, err := heap.DequeueMin(ctx)
+		require.NoError(t, err)
+		require.NotEmpty(t, key)
+		require.True(t, pmin >= prev)
+		prev = pmin
+	}
+	require.True(t, heap.IsEmpty())
+}
+
+func TestCrashRecovery(t *testing.T) {
+	ctx := context.Background()
+	for n := 0; ; n++ {
+		inner := inmem.NewInmemDb()
+		fdb := faulty.NewFaultyDb(inner, faulty.Config{})
+		heap, err := NewFibbonaciHeap(ctx, fdb)
+		require.NoError(t, err)
+		for i := 0; i < 20; i++ {
+			require.NoError(t, heap.Enqueue(ctx, strconv.Itoa(i), NumberSequence1[i]))
+		}
+
+		// each operation is a single write, stop at the crash
+		fdb.CrashAfter(n)
+		size := heap.Size()
+		for i := 20; i < 40 && !fdb.Crashed(); i++ {
+			durable := heap.Size()
+			if i%3 == 0 {
+				_, _, err = heap.DequeueMin(ctx)
+			} else {
+				err = heap.Enqueue(ctx, strconv.Itoa(i), NumberSequence1[i])
+			}
+			require.NoError(t, err)
+
+			size = heap.Size()
+			if fdb.Crashed() {
+				size = durable
+			}
+		}
+
+		requireConsistent(t, inner, size)
+		if !fdb.Crashed() {
+			return
+		}
+	}
+}
+
+func TestFailureRecovery(t *testing.T) {
+	ctx := context.Background()
+	inner := inmem.NewInmemDb()
+	fdb := faulty.NewFaultyDb(inner, faulty.Config{
+		Rules: []faulty.Rule{
+			{Ops: faulty.OpGet, Key: regexp.MustCompile("^/keys/"), Probability: 0.05},
+			{Ops: faulty.OpCommit, Probability: 0.2},
+		},
+		Seed: 1,
+	})
+	heap, err := NewFibbonaciHeap(ctx, fdb)
+	require.NoError(t, err)
+
+	var failed int
+	for i := 0; i < len(NumberSequence1); i++ {
+		key := strconv.Itoa(i)
+		switch i % 4 {
+		case 0:
+			_, _, err = heap.DequeueMin(ctx)
+		case 1:
+			err = heap.Delete(ctx, strconv.Itoa(i-1))
+		default:
+			err = heap.Enqueue(ctx, key, NumberSequence1[i])
+		}
+		if err != nil {
+			failed++
+		}
+	}
+	require.NotZero(t, failed)
+
+	// the heap is usable after the failures, and can be reopened
+	fdb.ClearRules()
+	size := heap.Size()
+	require.NoError(t, heap.Enqueue(ctx, "last", Seq1FirstMinimum-1))
+	key, _, err := heap.Min()
+	require.NoError(t, err)
+	require.Equal(t, "last", key)
+	requireConsistent(t, inner, size+1)
+}
+
 // ***************
 // BENCHMARK TESTS
 // ***************
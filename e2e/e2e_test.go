@@ -10,14 +10,12 @@ import (
 	"github.com/aperturerobotics/objstore/ipfs"
 	"github.com/aperturerobotics/objstore/localdb"
 	"github.com/aperturerobotics/pbobject"
-	"github.com/aperturerobotics/storageref"
 	"github.com/aperturerobotics/timestamp"
 
 	api "github.com/ipfs/go-ipfs-api"
 )
 
 // TestGetOrFetch tests getting or fetching an object from IPFS storage.
-// TODO: refactor to possibly use storageref as an argument to GetOrFetch?
 func TestGetOrFetch(t *testing.T) {
 	ctx := context.Background()
 	sh := api.NewLocalShell()
@@ -35,7 +33,7 @@ func TestGetOrFetch(t *testing.T) {
 	}
 
 	encConf := pbobject.EncryptionConfig{Context: ctx}
-	storageRef, objData, err := objStore.StoreObject(ctx, genesis, encConf)
+	storageRef, _, err := objStore.StoreObject(ctx, genesis, encConf)
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -43,20 +41,8 @@ func TestGetOrFetch(t *testing.T) {
 	t.Logf("storage reference: %s", storageRef)
 	defer sh.Unpin(storageRef.GetIpfs().GetReference())
 
-	digest, err := localStore.DigestData(objData)
-	if err != nil {
-		t.Fatal(err.Error())
-	}
-
 	outp := &inca.Genesis{}
-	if err := objStore.GetOrFetch(
-		ctx,
-		digest,
-		storageRef.GetIpfs().GetReference(),
-		storageRef.GetIpfs().GetIpfsRefType() == storageref.IPFSRefType_IPFSRefType_BLOCK,
-		outp,
-		encConf,
-	); err != nil {
+	if err := objStore.FetchRef(ctx, storageRef, outp, encConf); err != nil {
 		t.Fatal(err.Error())
 	}
 
@@ -0,0 +1,13 @@
+package objstore
+
+// FetchWaiters returns the number of callers waiting on in-flight fetches.
+func (o *ObjectStore) FetchWaiters() int {
+	o.fetchMtx.Lock()
+	defer o.fetchMtx.Unlock()
+
+	var n int
+	for _, call := range o.fetches {
+		n += call.refs
+	}
+	return n
+}
@@ -68,12 +68,24 @@ func (l *LocalDb) GetLocal(ctx context.Context, digest []byte, obj pbobject.Obje
 // hashPtr is a pointer to the expected unencrypted hash of the data. If the target array is nil,
 // the target will be written with the computed hash and not verified before storing.
 // If the target array is not nil, the hash will be checked before storage.
+// If params.TTL is set, the database must implement db.TTLSetter.
 func (l *LocalDb) StoreLocal(
 	ctx context.Context,
 	object pbobject.Object,
 	hashPtr *[]byte,
 	params objstore.StoreParams,
 ) error {
+	key, val, err := l.encodeObject(object, hashPtr)
+	if err != nil {
+		return err
+	}
+
+	return db.SetWithTTL(ctx, l.Db, key, val, params.TTL)
+}
+
+// encodeObject encodes an object and checks or writes its digest to hashPtr.
+// Returns the key and value to store.
+func (l *LocalDb) encodeObject(object pbobject.Object, hashPtr *[]byte) ([]byte, []byte, error) {
 	var digest []byte
 	if hashPtr != nil {
 		digest = *hashPtr
@@ -81,25 +93,94 @@ func (l *LocalDb) StoreLocal(
 
 	val, err := proto.Marshal(object)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	computedDigest, err := l.DigestData(val)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	if len(digest) != 0 {
 		if bytes.Compare(digest, computedDigest) != 0 {
-			return errors.New("digest of encoded data did not match given digest")
+			return nil, nil, errors.New("digest of encoded data did not match given digest")
 		}
 	} else if hashPtr != nil {
 		*hashPtr = computedDigest
 	}
 
-	digest = computedDigest
-	return l.Db.Set(ctx, l.GetDigestKey(digest), val)
+	return l.GetDigestKey(computedDigest), val, nil
+}
+
+// NewLocalTxn builds a new write transaction against the database.
+// The transaction is atomic if the database implements db.Batcher.
+func (l *LocalDb) NewLocalTxn(ctx context.Context) (objstore.LocalTxn, error) {
+	txn, err := db.NewTxn(ctx, l.Db, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &localTxn{l: l, txn: txn}, nil
+}
+
+// localTxn is a transaction against a LocalDb.
+type localTxn struct {
+	l   *LocalDb
+	txn db.Txn
+}
+
+// GetLocal returns an object by digest, including objects stored in the transaction.
+func (t *localTxn) GetLocal(ctx context.Context, digest []byte, obj pbobject.Object) error {
+	dat, datOk, err := t.txn.Get(ctx, t.l.GetDigestKey(digest))
+	if err != nil {
+		return err
+	}
+
+	if !datOk {
+		return objstore.ErrNotFound
+	}
+
+	return proto.Unmarshal(dat, obj)
+}
+
+// StoreLocal encodes an object and stores it in the transaction.
+// Transactions do not support a TTL, params.TTL must be zero.
+func (t *localTxn) StoreLocal(
+	ctx context.Context,
+	object pbobject.Object,
+	hashPtr *[]byte,
+	params objstore.StoreParams,
+) error {
+	if params.TTL != 0 {
+		return db.ErrTTLNotSupported
+	}
+
+	key, val, err := t.l.encodeObject(object, hashPtr)
+	if err != nil {
+		return err
+	}
+
+	return t.txn.Set(ctx, key, val)
+}
+
+// DigestData digests the data.
+func (t *localTxn) DigestData(data []byte) ([]byte, error) {
+	return t.l.DigestData(data)
+}
+
+// Commit atomically writes the objects stored in the transaction.
+func (t *localTxn) Commit(ctx context.Context) error {
+	return t.txn.Commit(ctx)
 }
 
-// _ is a type assertion
-var _ objstore.LocalStore = &LocalDb{}
+// Discard discards the transaction.
+func (t *localTxn) Discard() {
+	t.txn.Discard()
+}
+
+// _ are type assertions
+var (
+	_ objstore.LocalStore   = &LocalDb{}
+	_ objstore.LocalBatcher = &LocalDb{}
+	_ objstore.LocalTxn     = &localTxn{}
+)
@@ -3,6 +3,8 @@ package objstore
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/aperturerobotics/pbobject"
@@ -58,6 +60,13 @@ type ObjectStore struct {
 	RemoteStore
 
 	ctx context.Context
+	// fetchStoreParams are the params used to cache fetched objects.
+	fetchStoreParams StoreParams
+
+	// fetchMtx guards fetches
+	fetchMtx sync.Mutex
+	// fetches contains in-flight fetches keyed by fetchKey
+	fetches map[string]*fetchCall
 }
 
 // NewObjectStore builds a new object store.
@@ -65,12 +74,22 @@ func NewObjectStore(ctx context.Context, localStore LocalStore, remoteStore Remo
 	return &ObjectStore{ctx: ctx, LocalStore: localStore, RemoteStore: remoteStore}
 }
 
+// SetFetchStoreParams sets the parameters used when storing objects fetched
+// from the remote store in the local store, for example a TTL so that cached
+// objects age out. Should be called before the store is in use.
+func (o *ObjectStore) SetFetchStoreParams(params StoreParams) {
+	o.fetchStoreParams = params
+}
+
 // GetOrFetch returns an object by hash if it has been fetched into the
 // decrypted cache, or attempts to fetch the requested data from the backing
 // store (IPFS) given the reference string. This will start OR join a process to
 // attempt to fetch this storage ref with this hash.
-// TODO: If the function is called multiple times simultaneously, only one
-// actual fetch routine will be spawned.
+// If the function is called multiple times simultaneously, only one
+// actual fetch routine will be spawned. The fetched data is shared, and each
+// caller decodes and decrypts it with its own encryption config. The fetch is
+// canceled only when all of the callers waiting on it have canceled their
+// contexts.
 // The multihash code and length must match the database multihash code and
 // length or an error is returned.
 // The digest is of the innermost data of the object, unencrypted.
@@ -89,15 +108,66 @@ func (o *ObjectStore) GetOrFetch(
 		return getErr
 	}
 
-	// Call out to the remote database as the next layer of caches.
-	dat, err := o.FetchRemote(ctx, storageRef, isBlock)
-	if err != nil {
-		return err
+	// Start or join a fetch from the remote database.
+	key := fetchKey(storageRef, isBlock)
+	call := o.joinFetch(key, storageRef, isBlock)
+	defer o.leaveFetch(key, call)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-call.done:
 	}
-	if dat == nil {
-		return ErrNotFound
+
+	if call.err != nil {
+		return call.err
 	}
 
+	return o.decodeObject(call.dat, digest, obj, objWrapper, encConf)
+}
+
+// FetchRef returns an object given a storage reference, as returned by
+// StoreObject. DIGEST references are served from the local store, IPFS
+// references are fetched from the remote store if not cached locally.
+func (o *ObjectStore) FetchRef(
+	ctx context.Context,
+	ref *storageref.StorageRef,
+	obj pbobject.Object,
+	encConf pbobject.EncryptionConfig,
+) error {
+	if ref.IsEmpty() {
+		return errors.New("storage reference is empty")
+	}
+
+	digest := ref.GetObjectDigest()
+	switch ref.GetStorageType() {
+	case storageref.StorageType_StorageType_DIGEST:
+		return o.GetLocal(ctx, digest, obj)
+	case storageref.StorageType_StorageType_IPFS:
+		ipfsRef := ref.GetIpfs()
+		return o.GetOrFetch(
+			ctx,
+			digest,
+			ipfsRef.GetReference(),
+			ipfsRef.GetIpfsRefType() == storageref.IPFSRefType_IPFSRefType_BLOCK,
+			obj,
+			nil,
+			encConf,
+		)
+	default:
+		return fmt.Errorf("unsupported storage type: %v", ref.GetStorageType())
+	}
+}
+
+// decodeObject decodes, decrypts, and caches an object fetched from the remote
+// store. If objWrapper is set, the object wrapper is decoded to it.
+func (o *ObjectStore) decodeObject(
+	dat []byte,
+	digest []byte,
+	obj pbobject.Object,
+	objWrapper *pbobject.ObjectWrapper,
+	encConf pbobject.EncryptionConfig,
+) error {
 	// Attempt to decode and decrypt the wrapper.
 	if objWrapper == nil {
 		objWrapper = &pbobject.ObjectWrapper{}
@@ -111,8 +181,7 @@ func (o *ObjectStore) GetOrFetch(
 	}
 
 	// Write to the cache the data and confirm the digest.
-	// TODO: should store params be a argument?
-	return o.StoreLocal(o.ctx, obj, &digest, StoreParams{})
+	return o.StoreLocal(o.ctx, obj, &digest, o.fetchStoreParams)
 }
 
 // StoreObject digests, seals, encrypts, and stores a object locally and remotely.
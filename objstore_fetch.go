@@ -0,0 +1,86 @@
+package objstore
+
+import (
+	"context"
+	"strconv"
+)
+
+// fetchCall is an in-flight fetch shared by concurrent GetOrFetch calls.
+type fetchCall struct {
+	// refs is the number of waiting callers, guarded by fetchMtx.
+	refs int
+	// cancel cancels the fetch context.
+	cancel context.CancelFunc
+	// done is closed when the fetch completes.
+	done chan struct{}
+
+	// dat and err are set before done is closed.
+	// dat is the encoded object wrapper, which must not be modified.
+	dat []byte
+	err error
+}
+
+// fetchKey returns the key used to join in-flight fetches.
+func fetchKey(storageRef string, isBlock bool) string {
+	return strconv.FormatBool(isBlock) + "/" + storageRef
+}
+
+// joinFetch joins an in-flight fetch, or starts one if none exists.
+// The caller must call leaveFetch when done waiting.
+func (o *ObjectStore) joinFetch(key string, storageRef string, isBlock bool) *fetchCall {
+	o.fetchMtx.Lock()
+	defer o.fetchMtx.Unlock()
+
+	if call, ok := o.fetches[key]; ok {
+		call.refs++
+		return call
+	}
+
+	if o.fetches == nil {
+		o.fetches = make(map[string]*fetchCall)
+	}
+
+	ctx, ctxCancel := context.WithCancel(o.ctx)
+	call := &fetchCall{
+		refs:   1,
+		cancel: ctxCancel,
+		done:   make(chan struct{}),
+	}
+	o.fetches[key] = call
+
+	go func() {
+		// Call out to the remote database as the next layer of caches.
+		dat, err := o.FetchRemote(ctx, storageRef, isBlock)
+		if err == nil && dat == nil {
+			err = ErrNotFound
+		}
+		ctxCancel()
+
+		o.fetchMtx.Lock()
+		if o.fetches[key] == call {
+			delete(o.fetches, key)
+		}
+		call.dat, call.err = dat, err
+		close(call.done)
+		o.fetchMtx.Unlock()
+	}()
+
+	return call
+}
+
+// leaveFetch stops waiting on a fetch.
+// If no callers are left waiting, the fetch is canceled.
+func (o *ObjectStore) leaveFetch(key string, call *fetchCall) {
+	o.fetchMtx.Lock()
+	defer o.fetchMtx.Unlock()
+
+	call.refs--
+	if call.refs != 0 {
+		return
+	}
+
+	call.cancel()
+	if o.fetches[key] == call {
+		delete(o.fetches, key)
+	}
+}
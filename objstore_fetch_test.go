@@ -0,0 +1,162 @@
+package objstore_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aperturerobotics/objstore"
+	"github.com/aperturerobotics/objstore/db/inmem"
+	"github.com/aperturerobotics/objstore/dbds/btree"
+	"github.com/aperturerobotics/objstore/localdb"
+	"github.com/aperturerobotics/pbobject"
+	"github.com/aperturerobotics/storageref"
+	"github.com/stretchr/testify/require"
+)
+
+// testRemote is a remote store which blocks fetches until released.
+type testRemote struct {
+	mtx     sync.Mutex
+	blobs   map[string][]byte
+	fetches int
+	// release is closed to complete fetches.
+	release chan struct{}
+	// canceled is closed when a fetch context is canceled.
+	canceled chan struct{}
+}
+
+// newTestRemote builds a new test remote store.
+func newTestRemote() *testRemote {
+	return &testRemote{
+		blobs:    make(map[string][]byte),
+		release:  make(chan struct{}),
+		canceled: make(chan struct{}),
+	}
+}
+
+// FetchRemote returns a blob once the remote is released.
+func (r *testRemote) FetchRemote(ctx context.Context, storageRef string, isBlock bool) ([]byte, error) {
+	r.mtx.Lock()
+	r.fetches++
+	blob := r.blobs[storageRef]
+	r.mtx.Unlock()
+
+	select {
+	case <-r.release:
+		return blob, nil
+	case <-ctx.Done():
+		close(r.canceled)
+		return nil, ctx.Err()
+	}
+}
+
+// StoreRemote stores a blob.
+func (r *testRemote) StoreRemote(ctx context.Context, blob []byte) (string, bool, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	ref := "ref-" + strconv.Itoa(len(r.blobs))
+	r.blobs[ref] = blob
+	return ref, true, nil
+}
+
+// Fetches returns the number of fetches.
+func (r *testRemote) Fetches() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.fetches
+}
+
+// storeTestObject stores a test object with the remote, returning the ref.
+func storeTestObject(t *testing.T, remote *testRemote) *storageref.StorageRef {
+	ctx := context.Background()
+	src := objstore.NewObjectStore(ctx, localdb.NewLocalDb(inmem.NewInmemDb()), remote)
+	ref, _, err := src.StoreObject(ctx, &btree.Root{Length: 3}, pbobject.EncryptionConfig{})
+	require.NoError(t, err)
+	return ref
+}
+
+// TestGetOrFetchJoin tests concurrent fetches of the same ref are joined.
+func TestGetOrFetchJoin(t *testing.T) {
+	ctx := context.Background()
+	remote := newTestRemote()
+	ref := storeTestObject(t, remote)
+	objStore := objstore.NewObjectStore(ctx, localdb.NewLocalDb(inmem.NewInmemDb()), remote)
+
+	const callers = 4
+	errCh := make(chan error, callers)
+	objs := make([]*btree.Root, callers)
+	for i := range objs {
+		objs[i] = &btree.Root{}
+		go func(obj *btree.Root) {
+			errCh <- objStore.FetchRef(ctx, ref, obj, pbobject.EncryptionConfig{})
+		}(objs[i])
+	}
+
+	require.Eventually(t, func() bool {
+		return objStore.FetchWaiters() == callers
+	}, 5*time.Second, time.Millisecond)
+	close(remote.release)
+	for i := 0; i < callers; i++ {
+		require.NoError(t, <-errCh)
+	}
+
+	require.Equal(t, 1, remote.Fetches())
+	for _, obj := range objs {
+		require.Equal(t, uint32(3), obj.GetLength())
+	}
+	require.Equal(t, 0, objStore.FetchWaiters())
+
+	// the object was cached locally
+	obj := &btree.Root{}
+	require.NoError(t, objStore.FetchRef(ctx, ref, obj, pbobject.EncryptionConfig{}))
+	require.Equal(t, 1, remote.Fetches())
+	require.Equal(t, uint32(3), obj.GetLength())
+}
+
+// TestGetOrFetchCancel tests the fetch is canceled once all callers leave.
+func TestGetOrFetchCancel(t *testing.T) {
+	ctx := context.Background()
+	remote := newTestRemote()
+	ref := storeTestObject(t, remote)
+	objStore := objstore.NewObjectStore(ctx, localdb.NewLocalDb(inmem.NewInmemDb()), remote)
+
+	ctxA, ctxCancelA := context.WithCancel(ctx)
+	defer ctxCancelA()
+	ctxB, ctxCancelB := context.WithCancel(ctx)
+	defer ctxCancelB()
+
+	errA, errB := make(chan error, 1), make(chan error, 1)
+	go func() {
+		errA <- objStore.FetchRef(ctxA, ref, &btree.Root{}, pbobject.EncryptionConfig{})
+	}()
+	go func() {
+		errB <- objStore.FetchRef(ctxB, ref, &btree.Root{}, pbobject.EncryptionConfig{})
+	}()
+	require.Eventually(t, func() bool {
+		return objStore.FetchWaiters() == 2
+	}, 5*time.Second, time.Millisecond)
+
+	// the fetch continues while a caller is waiting
+	ctxCancelA()
+	require.Equal(t, context.Canceled, <-errA)
+	require.Equal(t, 1, objStore.FetchWaiters())
+	select {
+	case <-remote.canceled:
+		t.Fatal("fetch canceled while a caller is waiting")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ctxCancelB()
+	require.Equal(t, context.Canceled, <-errB)
+	select {
+	case <-remote.canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for fetch to be canceled")
+	}
+	require.Equal(t, 0, objStore.FetchWaiters())
+	require.Equal(t, 1, remote.Fetches())
+}
@@ -0,0 +1,65 @@
+package objstore
+
+import (
+	"context"
+)
+
+// LocalTxn is a transaction against the local store.
+// Objects stored in the transaction are written together on Commit.
+type LocalTxn interface {
+	LocalStore
+
+	// Commit atomically writes the objects stored in the transaction.
+	Commit(ctx context.Context) error
+	// Discard discards the transaction. Safe to call after Commit.
+	Discard()
+}
+
+// LocalBatcher is a local store which supports atomic transactions.
+type LocalBatcher interface {
+	// NewLocalTxn builds a new write transaction against the local store.
+	NewLocalTxn(ctx context.Context) (LocalTxn, error)
+}
+
+// ObjectStoreTxn is an object store which writes to the local store in a
+// transaction. Writes to the remote store are not transactional.
+type ObjectStoreTxn struct {
+	*ObjectStore
+
+	txn LocalTxn
+}
+
+// NewTxn builds an object store which writes objects to the local store in a
+// transaction, applied on Commit. If the local store does not implement
+// LocalBatcher, objects are written immediately and Commit is a no-op.
+func (o *ObjectStore) NewTxn(ctx context.Context) (*ObjectStoreTxn, error) {
+	b, ok := o.LocalStore.(LocalBatcher)
+	if !ok {
+		return &ObjectStoreTxn{ObjectStore: o}, nil
+	}
+
+	txn, err := b.NewLocalTxn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := NewObjectStore(o.ctx, txn, o.RemoteStore)
+	ts.SetFetchStoreParams(o.fetchStoreParams)
+	return &ObjectStoreTxn{ObjectStore: ts, txn: txn}, nil
+}
+
+// Commit writes the objects stored in the transaction to the local store.
+func (t *ObjectStoreTxn) Commit(ctx context.Context) error {
+	if t.txn == nil {
+		return nil
+	}
+
+	return t.txn.Commit(ctx)
+}
+
+// Discard discards the transaction. Safe to call after Commit.
+func (t *ObjectStoreTxn) Discard() {
+	if t.txn != nil {
+		t.txn.Discard()
+	}
+}